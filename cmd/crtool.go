@@ -2,17 +2,22 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"log"
+	"os"
 
 	"github.com/sgnn7/crtool/pkg/cli"
+	"github.com/sgnn7/crtool/pkg/ssl"
 )
 
 func main() {
 	err := cli.RunCRTool()
 	if err != nil {
-		log.Fatal(
-			errors.New(fmt.Sprintf("ERROR: %s", err.Error())),
-		)
+		if errors.Is(err, ssl.ErrValidationWarning) {
+			log.Printf("WARNING: %s", err.Error())
+		} else {
+			log.Printf("ERROR: %s", err.Error())
+		}
 	}
+
+	os.Exit(cli.ExitCode(err))
 }
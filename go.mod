@@ -1,5 +1,8 @@
 module github.com/sgnn7/crtool
 
-go 1.13
+go 1.17
 
-require golang.org/x/crypto v0.0.0-20200604202706-70a84ac30bf9
+require (
+	golang.org/x/crypto v0.0.0-20220331220935-ae2d96664a29
+	software.sslmate.com/src/go-pkcs12 v0.2.0
+)
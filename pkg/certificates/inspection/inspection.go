@@ -0,0 +1,422 @@
+package inspection
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "Digital Signature"},
+	{x509.KeyUsageContentCommitment, "Content Commitment"},
+	{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+	{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+	{x509.KeyUsageKeyAgreement, "Key Agreement"},
+	{x509.KeyUsageCertSign, "Certificate Sign"},
+	{x509.KeyUsageCRLSign, "CRL Sign"},
+	{x509.KeyUsageEncipherOnly, "Encipher Only"},
+	{x509.KeyUsageDecipherOnly, "Decipher Only"},
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "Any",
+	x509.ExtKeyUsageServerAuth:                     "TLS Web Server Authentication",
+	x509.ExtKeyUsageClientAuth:                     "TLS Web Client Authentication",
+	x509.ExtKeyUsageCodeSigning:                    "Code Signing",
+	x509.ExtKeyUsageEmailProtection:                "E-mail Protection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "IPSec End System",
+	x509.ExtKeyUsageIPSECTunnel:                    "IPSec Tunnel",
+	x509.ExtKeyUsageIPSECUser:                      "IPSec User",
+	x509.ExtKeyUsageTimeStamping:                   "Time Stamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSP Signing",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "Microsoft Server Gated Crypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "Netscape Server Gated Crypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "Microsoft Commercial Code Signing",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "Microsoft Kernel Code Signing",
+}
+
+var extensionNames = map[string]string{
+	"2.5.29.14":               "Subject Key Identifier",
+	"2.5.29.15":               "Key Usage",
+	"2.5.29.17":               "Subject Alternative Name",
+	"2.5.29.19":               "Basic Constraints",
+	"2.5.29.30":               "Name Constraints",
+	"2.5.29.31":               "CRL Distribution Points",
+	"2.5.29.32":               "Certificate Policies",
+	"2.5.29.35":               "Authority Key Identifier",
+	"2.5.29.37":               "Extended Key Usage",
+	"1.3.6.1.5.5.7.1.1":       "Authority Information Access",
+	"1.3.6.1.5.5.7.1.24":      "TLS Feature",
+	"1.3.6.1.4.1.11129.2.4.2": "Signed Certificate Timestamps",
+	"1.3.6.1.4.1.11129.2.4.3": "Precertificate Poison",
+}
+
+// Formats bytes as colon-separated uppercase hex (e.g. 'AB:CD:EF')
+func HexString(data []byte) string {
+	hexBytes := make([]string, len(data))
+	for idx, value := range data {
+		hexBytes[idx] = fmt.Sprintf("%02X", value)
+	}
+
+	return strings.Join(hexBytes, ":")
+}
+
+// Describes the type and size of a public key (e.g. 'RSA 2048 bit')
+func PublicKeyDescription(cert *x509.Certificate) string {
+	switch publicKey := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d bit", publicKey.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %d bit (%s)", publicKey.Curve.Params().BitSize,
+			publicKey.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519 256 bit"
+	case *dsa.PublicKey:
+		return fmt.Sprintf("DSA %d bit", publicKey.P.BitLen())
+	}
+
+	return cert.PublicKeyAlgorithm.String()
+}
+
+func KeyUsageNames(keyUsage x509.KeyUsage) []string {
+	names := []string{}
+	for _, keyUsageName := range keyUsageNames {
+		if keyUsage&keyUsageName.usage != 0 {
+			names = append(names, keyUsageName.name)
+		}
+	}
+
+	return names
+}
+
+// Lists the extensions of the certificate by name and OID (e.g. 'Key Usage (2.5.29.15,
+// critical)')
+func ExtensionNames(cert *x509.Certificate) []string {
+	names := []string{}
+	for _, extension := range cert.Extensions {
+		oid := extension.Id.String()
+		if extension.Critical {
+			oid += ", critical"
+		}
+
+		name, ok := extensionNames[extension.Id.String()]
+		if !ok {
+			name = "Unknown"
+		}
+		names = append(names, fmt.Sprintf("%s (%s)", name, oid))
+	}
+
+	return names
+}
+
+func ExtKeyUsageNames(cert *x509.Certificate) []string {
+	names := []string{}
+	for _, extKeyUsage := range cert.ExtKeyUsage {
+		name, ok := extKeyUsageNames[extKeyUsage]
+		if !ok {
+			name = fmt.Sprintf("Unknown (%d)", extKeyUsage)
+		}
+		names = append(names, name)
+	}
+
+	for _, oid := range cert.UnknownExtKeyUsage {
+		names = append(names, oid.String())
+	}
+
+	return names
+}
+
+type NameConstraints struct {
+	Critical  bool     `json:"critical"`
+	Permitted []string `json:"permitted"`
+	Excluded  []string `json:"excluded"`
+}
+
+type SCTDetails struct {
+	Version            int       `json:"version"`
+	LogID              string    `json:"log_id"`
+	Timestamp          time.Time `json:"timestamp"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+}
+
+// Decoded content of a certificate. Field names are part of the JSON output schema so they
+// must not be renamed.
+type Details struct {
+	Version            int       `json:"version"`
+	SerialNumber       string    `json:"serial_number"`
+	SerialNumberInt    string    `json:"serial_number_int"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	Issuer             string    `json:"issuer"`
+	Subject            string    `json:"subject"`
+	CommonName         string    `json:"common_name"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+
+	PublicKeyAlgorithm string `json:"public_key_algorithm"`
+	PublicKey          string `json:"public_key"`
+
+	DNSNames       []string `json:"dns_names"`
+	IPAddresses    []string `json:"ip_addresses"`
+	EmailAddresses []string `json:"email_addresses"`
+	URIs           []string `json:"uris"`
+
+	KeyUsage    []string `json:"key_usage"`
+	ExtKeyUsage []string `json:"ext_key_usage"`
+
+	BasicConstraintsValid bool `json:"basic_constraints_valid"`
+	IsCA                  bool `json:"is_ca"`
+
+	// Only set if the path length is constrained
+	MaxPathLen *int `json:"max_path_len"`
+
+	NameConstraints NameConstraints `json:"name_constraints"`
+
+	Policies               []string `json:"policies"`
+	OCSPServers            []string `json:"ocsp_servers"`
+	IssuingCertificateURLs []string `json:"issuing_certificate_urls"`
+	CRLDistributionPoints  []string `json:"crl_distribution_points"`
+
+	SubjectKeyID   string `json:"subject_key_id"`
+	AuthorityKeyID string `json:"authority_key_id"`
+
+	SCTs     []SCTDetails `json:"scts"`
+	SCTError string       `json:"sct_error,omitempty"`
+
+	SHA1Fingerprint   string `json:"sha1_fingerprint"`
+	SHA256Fingerprint string `json:"sha256_fingerprint"`
+}
+
+// Lists are never nil so that they are output as empty lists instead of nulls
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
+
+func NewDetails(cert *x509.Certificate) Details {
+	details := Details{
+		Version:            cert.Version,
+		SerialNumber:       HexString(cert.SerialNumber.Bytes()),
+		SerialNumberInt:    cert.SerialNumber.String(),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		Issuer:             cert.Issuer.String(),
+		Subject:            cert.Subject.String(),
+		CommonName:         cert.Subject.CommonName,
+		NotBefore:          cert.NotBefore.UTC(),
+		NotAfter:           cert.NotAfter.UTC(),
+
+		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		PublicKey:          PublicKeyDescription(cert),
+
+		DNSNames:       nonNil(cert.DNSNames),
+		IPAddresses:    []string{},
+		EmailAddresses: nonNil(cert.EmailAddresses),
+		URIs:           []string{},
+
+		KeyUsage:    KeyUsageNames(cert.KeyUsage),
+		ExtKeyUsage: ExtKeyUsageNames(cert),
+
+		BasicConstraintsValid: cert.BasicConstraintsValid,
+		IsCA:                  cert.IsCA,
+
+		NameConstraints: NameConstraints{
+			Critical:  cert.PermittedDNSDomainsCritical,
+			Permitted: append([]string{}, cert.PermittedDNSDomains...),
+			Excluded:  append([]string{}, cert.ExcludedDNSDomains...),
+		},
+
+		Policies:               []string{},
+		OCSPServers:            nonNil(cert.OCSPServer),
+		IssuingCertificateURLs: nonNil(cert.IssuingCertificateURL),
+		CRLDistributionPoints:  nonNil(cert.CRLDistributionPoints),
+
+		SubjectKeyID:   HexString(cert.SubjectKeyId),
+		AuthorityKeyID: HexString(cert.AuthorityKeyId),
+
+		SCTs: []SCTDetails{},
+	}
+
+	for _, ip := range cert.IPAddresses {
+		details.IPAddresses = append(details.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		details.URIs = append(details.URIs, uri.String())
+	}
+
+	if cert.BasicConstraintsValid && (cert.MaxPathLen > 0 || cert.MaxPathLenZero) {
+		maxPathLen := cert.MaxPathLen
+		details.MaxPathLen = &maxPathLen
+	}
+
+	nameConstraints := &details.NameConstraints
+	for _, ipRange := range cert.PermittedIPRanges {
+		nameConstraints.Permitted = append(nameConstraints.Permitted, ipRange.String())
+	}
+	nameConstraints.Permitted = append(nameConstraints.Permitted, cert.PermittedEmailAddresses...)
+	nameConstraints.Permitted = append(nameConstraints.Permitted, cert.PermittedURIDomains...)
+
+	for _, ipRange := range cert.ExcludedIPRanges {
+		nameConstraints.Excluded = append(nameConstraints.Excluded, ipRange.String())
+	}
+	nameConstraints.Excluded = append(nameConstraints.Excluded, cert.ExcludedEmailAddresses...)
+	nameConstraints.Excluded = append(nameConstraints.Excluded, cert.ExcludedURIDomains...)
+
+	for _, policy := range cert.PolicyIdentifiers {
+		details.Policies = append(details.Policies, policy.String())
+	}
+
+	scts, err := ParseSCTs(cert)
+	if err != nil {
+		details.SCTError = err.Error()
+	}
+	for _, sct := range scts {
+		details.SCTs = append(details.SCTs, SCTDetails{
+			Version:            int(sct.Version) + 1,
+			LogID:              base64.StdEncoding.EncodeToString(sct.LogID),
+			Timestamp:          sct.Timestamp,
+			SignatureAlgorithm: sct.SignatureAlgorithmName(),
+		})
+	}
+
+	sha1Fingerprint := sha1.Sum(cert.Raw)
+	sha256Fingerprint := sha256.Sum256(cert.Raw)
+	details.SHA1Fingerprint = HexString(sha1Fingerprint[:])
+	details.SHA256Fingerprint = HexString(sha256Fingerprint[:])
+
+	return details
+}
+
+type describer struct {
+	builder strings.Builder
+}
+
+func (describer *describer) line(indent int, format string, args ...interface{}) {
+	describer.builder.WriteString(strings.Repeat("    ", indent))
+	fmt.Fprintf(&describer.builder, format, args...)
+	describer.builder.WriteString("\n")
+}
+
+// Writes a labeled list of values that is omitted if there are no values
+func (describer *describer) list(indent int, label string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	describer.line(indent, "%s", label)
+	for _, value := range values {
+		describer.line(indent+1, "%s", value)
+	}
+}
+
+func prefixed(prefix string, values []string) []string {
+	prefixedValues := make([]string, len(values))
+	for idx, value := range values {
+		prefixedValues[idx] = prefix + value
+	}
+
+	return prefixedValues
+}
+
+// Renders the decoded content of a certificate as human-readable text
+func (details Details) String() string {
+	describer := &describer{}
+
+	describer.line(0, "Version: %d", details.Version)
+	describer.line(0, "Serial Number: %s (%s)", details.SerialNumber,
+		details.SerialNumberInt)
+	describer.line(0, "Signature Algorithm: %s", details.SignatureAlgorithm)
+	describer.line(0, "Issuer: %s", details.Issuer)
+	describer.line(0, "Subject: %s", details.Subject)
+
+	describer.line(0, "Validity:")
+	describer.line(1, "Not Before: %s", details.NotBefore.Format(time.RFC3339))
+	describer.line(1, "Not After:  %s", details.NotAfter.Format(time.RFC3339))
+	if remaining := time.Until(details.NotAfter); remaining > 0 {
+		describer.line(1, "Expires in %d day(s)", int(remaining.Hours()/24))
+	} else {
+		describer.line(1, "Expired %d day(s) ago", int(-remaining.Hours()/24))
+	}
+
+	describer.line(0, "Public Key: %s", details.PublicKey)
+
+	describer.line(0, "Extensions:")
+
+	subjectAltNames := prefixed("DNS: ", details.DNSNames)
+	subjectAltNames = append(subjectAltNames, prefixed("IP: ", details.IPAddresses)...)
+	subjectAltNames = append(subjectAltNames, prefixed("Email: ", details.EmailAddresses)...)
+	subjectAltNames = append(subjectAltNames, prefixed("URI: ", details.URIs)...)
+	describer.list(1, "Subject Alternative Names:", subjectAltNames)
+
+	describer.list(1, "Key Usage:", details.KeyUsage)
+	describer.list(1, "Extended Key Usage:", details.ExtKeyUsage)
+
+	if details.BasicConstraintsValid {
+		describer.line(1, "Basic Constraints:")
+		describer.line(2, "CA: %v", details.IsCA)
+		if details.MaxPathLen != nil {
+			describer.line(2, "Path Length: %d", *details.MaxPathLen)
+		}
+	}
+
+	nameConstraints := details.NameConstraints
+	if len(nameConstraints.Permitted) > 0 || len(nameConstraints.Excluded) > 0 {
+		if nameConstraints.Critical {
+			describer.line(1, "Name Constraints (critical):")
+		} else {
+			describer.line(1, "Name Constraints:")
+		}
+		describer.list(2, "Permitted:", nameConstraints.Permitted)
+		describer.list(2, "Excluded:", nameConstraints.Excluded)
+	}
+
+	describer.list(1, "Certificate Policies:", details.Policies)
+
+	authorityInfo := prefixed("OCSP: ", details.OCSPServers)
+	authorityInfo = append(authorityInfo, prefixed("CA Issuers: ", details.IssuingCertificateURLs)...)
+	describer.list(1, "Authority Information Access:", authorityInfo)
+
+	describer.list(1, "CRL Distribution Points:", details.CRLDistributionPoints)
+
+	if details.SubjectKeyID != "" {
+		describer.line(1, "Subject Key Identifier: %s", details.SubjectKeyID)
+	}
+	if details.AuthorityKeyID != "" {
+		describer.line(1, "Authority Key Identifier: %s", details.AuthorityKeyID)
+	}
+
+	if details.SCTError != "" {
+		describer.line(1, "Signed Certificate Timestamps: %s", details.SCTError)
+	} else if len(details.SCTs) > 0 {
+		describer.line(1, "Signed Certificate Timestamps:")
+		for _, sct := range details.SCTs {
+			describer.line(2, "Log ID: %s", sct.LogID)
+			describer.line(3, "Version: v%d", sct.Version)
+			describer.line(3, "Timestamp: %s", sct.Timestamp.Format(time.RFC3339))
+			describer.line(3, "Signature Algorithm: %s", sct.SignatureAlgorithm)
+		}
+	}
+
+	describer.line(0, "Fingerprints:")
+	describer.line(1, "SHA-1:   %s", details.SHA1Fingerprint)
+	describer.line(1, "SHA-256: %s", details.SHA256Fingerprint)
+
+	return describer.builder.String()
+}
+
+// Renders the full decoded content of a certificate as human-readable text
+func DescribeCertificate(cert *x509.Certificate) string {
+	return NewDetails(cert).String()
+}
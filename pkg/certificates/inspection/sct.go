@@ -0,0 +1,165 @@
+package inspection
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// https://tools.ietf.org/html/rfc6962#section-3.3
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// https://tools.ietf.org/html/rfc5246#section-7.4.1.4.1
+var (
+	sctHashAlgorithms = map[uint8]string{
+		1: "MD5",
+		2: "SHA1",
+		3: "SHA224",
+		4: "SHA256",
+		5: "SHA384",
+		6: "SHA512",
+	}
+
+	sctSignatureAlgorithms = map[uint8]string{
+		1: "RSA",
+		2: "DSA",
+		3: "ECDSA",
+	}
+)
+
+// Signed certificate timestamp embedded by a Certificate Transparency log
+type SignedCertificateTimestamp struct {
+	Version            uint8
+	LogID              []byte
+	Timestamp          time.Time
+	Extensions         []byte
+	HashAlgorithm      uint8
+	SignatureAlgorithm uint8
+	Signature          []byte
+}
+
+func (sct SignedCertificateTimestamp) SignatureAlgorithmName() string {
+	hash, ok := sctHashAlgorithms[sct.HashAlgorithm]
+	if !ok {
+		hash = fmt.Sprintf("hash(%d)", sct.HashAlgorithm)
+	}
+
+	signature, ok := sctSignatureAlgorithms[sct.SignatureAlgorithm]
+	if !ok {
+		signature = fmt.Sprintf("signature(%d)", sct.SignatureAlgorithm)
+	}
+
+	return fmt.Sprintf("%s-with-%s", hash, signature)
+}
+
+type tlsReader struct {
+	data []byte
+	err  error
+}
+
+func (reader *tlsReader) readBytes(length int) []byte {
+	if reader.err != nil {
+		return nil
+	}
+
+	if length > len(reader.data) {
+		reader.err = errors.New("SCT list is truncated")
+		return nil
+	}
+
+	value := reader.data[:length]
+	reader.data = reader.data[length:]
+	return value
+}
+
+func (reader *tlsReader) readUint8() uint8 {
+	if value := reader.readBytes(1); value != nil {
+		return value[0]
+	}
+
+	return 0
+}
+
+func (reader *tlsReader) readUint16() uint16 {
+	if value := reader.readBytes(2); value != nil {
+		return binary.BigEndian.Uint16(value)
+	}
+
+	return 0
+}
+
+func (reader *tlsReader) readUint64() uint64 {
+	if value := reader.readBytes(8); value != nil {
+		return binary.BigEndian.Uint64(value)
+	}
+
+	return 0
+}
+
+func (reader *tlsReader) readVector16() []byte {
+	return reader.readBytes(int(reader.readUint16()))
+}
+
+func parseSCT(data []byte) (SignedCertificateTimestamp, error) {
+	reader := &tlsReader{data: data}
+
+	sct := SignedCertificateTimestamp{
+		Version: reader.readUint8(),
+		LogID:   reader.readBytes(32),
+	}
+
+	timestamp := reader.readUint64()
+	sct.Timestamp = time.Unix(0, int64(timestamp)*int64(time.Millisecond)).UTC()
+	sct.Extensions = reader.readVector16()
+	sct.HashAlgorithm = reader.readUint8()
+	sct.SignatureAlgorithm = reader.readUint8()
+	sct.Signature = reader.readVector16()
+
+	return sct, reader.err
+}
+
+// Parses the SCTs embedded in a certificate's SCT list extension
+func ParseSCTs(cert *x509.Certificate) ([]SignedCertificateTimestamp, error) {
+	scts := []SignedCertificateTimestamp{}
+
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(oidSCTList) {
+			continue
+		}
+
+		// The TLS-encoded list is wrapped in an additional octet string
+		var sctList []byte
+		if _, err := asn1.Unmarshal(extension.Value, &sctList); err != nil {
+			return nil, errors.New(fmt.Sprintf("could not parse SCT list: %s", err.Error()))
+		}
+
+		listReader := &tlsReader{data: sctList}
+		entriesReader := &tlsReader{data: listReader.readVector16()}
+		for listReader.err == nil && len(entriesReader.data) > 0 {
+			sctData := entriesReader.readVector16()
+			if entriesReader.err != nil {
+				break
+			}
+
+			sct, err := parseSCT(sctData)
+			if err != nil {
+				return nil, err
+			}
+
+			scts = append(scts, sct)
+		}
+
+		if listReader.err != nil {
+			return nil, listReader.err
+		}
+
+		if entriesReader.err != nil {
+			return nil, entriesReader.err
+		}
+	}
+
+	return scts, nil
+}
@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"bytes"
+	"crypto/x509"
+)
+
+func isIssuerOf(issuer *x509.Certificate, cert *x509.Certificate) bool {
+	return issuer != cert && bytes.Equal(issuer.RawSubject, cert.RawIssuer)
+}
+
+// Orders certificates from bundles without a defined order (keystores, PKCS#7) as a chain:
+// the leaf first followed by its issuers. Certificates that are not part of the leaf's chain
+// are appended in their original order.
+func orderCertificateChain(certs []*x509.Certificate) []*x509.Certificate {
+	if len(certs) < 2 {
+		return certs
+	}
+
+	// The leaf is the first certificate that has not issued any of the others
+	leafIdx := 0
+	for idx, cert := range certs {
+		isIssuer := false
+		for _, other := range certs {
+			if isIssuerOf(cert, other) && !bytes.Equal(other.RawSubject, other.RawIssuer) {
+				isIssuer = true
+				break
+			}
+		}
+
+		if !isIssuer {
+			leafIdx = idx
+			break
+		}
+	}
+
+	used := make([]bool, len(certs))
+	used[leafIdx] = true
+	chain := []*x509.Certificate{certs[leafIdx]}
+
+	for current := certs[leafIdx]; !bytes.Equal(current.RawSubject, current.RawIssuer); {
+		nextIdx := -1
+		for idx, cert := range certs {
+			if !used[idx] && isIssuerOf(cert, current) {
+				nextIdx = idx
+				break
+			}
+		}
+
+		if nextIdx < 0 {
+			break
+		}
+
+		used[nextIdx] = true
+		current = certs[nextIdx]
+		chain = append(chain, current)
+	}
+
+	for idx, cert := range certs {
+		if !used[idx] {
+			chain = append(chain, cert)
+		}
+	}
+
+	return chain
+}
@@ -0,0 +1,130 @@
+package providers
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+// Loads the client identity from PEM certificate and key files or from a PKCS#12 keystore
+func loadClientCertificate(options Options) (*tls.Certificate, error) {
+	certData, err := ioutil.ReadFile(options.ClientCertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.ClientKeyFile == "" && isPKCS12Data(options.ClientCertFile, certData) {
+		certs, key, err := decodePKCS12(certData, options.ClientCertPassword)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == nil || len(certs) == 0 {
+			return nil, errors.New(fmt.Sprintf("'%s' does not contain both a certificate and a key",
+				options.ClientCertFile))
+		}
+
+		clientCert := &tls.Certificate{
+			PrivateKey: key,
+			Leaf:       certs[0],
+		}
+		for _, cert := range certs {
+			clientCert.Certificate = append(clientCert.Certificate, cert.Raw)
+		}
+
+		return clientCert, nil
+	}
+
+	// The key may be bundled in the same PEM file as the certificate
+	keyData := certData
+	if options.ClientKeyFile != "" {
+		keyData, err = ioutil.ReadFile(options.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	clientCert, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("could not load client certificate: %s", err.Error()))
+	}
+
+	return &clientCert, nil
+}
+
+func formatAcceptableCAs(acceptableCAs [][]byte) []string {
+	names := make([]string, 0, len(acceptableCAs))
+	for _, rawName := range acceptableCAs {
+		var rdnSequence pkix.RDNSequence
+		if _, err := asn1.Unmarshal(rawName, &rdnSequence); err != nil {
+			names = append(names, fmt.Sprintf("<unparsable name: %x>", rawName))
+			continue
+		}
+
+		var name pkix.Name
+		name.FillFromRDNSequence(&rdnSequence)
+		names = append(names, name.String())
+	}
+
+	return names
+}
+
+// Tracks whether the server asked for a client certificate during the handshake
+type clientCertificateRequest struct {
+	requested     bool
+	acceptableCAs []string
+}
+
+func (request *clientCertificateRequest) log() {
+	if !request.requested {
+		return
+	}
+
+	if len(request.acceptableCAs) == 0 {
+		log.Printf("Server requested a client certificate (no acceptable CA names advertised)")
+		return
+	}
+
+	log.Printf("Server requested a client certificate issued by one of:")
+	for _, caName := range request.acceptableCAs {
+		log.Printf("  - %s", caName)
+	}
+}
+
+func configureClientCertificate(
+	tlsConfig *tls.Config,
+	options Options,
+) (*clientCertificateRequest, error) {
+
+	var clientCert *tls.Certificate
+	if options.ClientCertFile != "" {
+		var err error
+		clientCert, err = loadClientCertificate(options)
+		if err != nil {
+			return nil, err
+		}
+
+		if options.Debug {
+			log.Printf("Loaded client certificate from '%s'", options.ClientCertFile)
+		}
+	}
+
+	request := &clientCertificateRequest{}
+	tlsConfig.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		request.requested = true
+		request.acceptableCAs = formatAcceptableCAs(info.AcceptableCAs)
+
+		if clientCert == nil {
+			// Sending no certificate lets the server decide whether to continue
+			return &tls.Certificate{}, nil
+		}
+
+		return clientCert, nil
+	}
+
+	return request, nil
+}
@@ -1,46 +1,176 @@
 package providers
 
 import (
+	"bytes"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/sgnn7/crtool/pkg/encoding"
+)
+
+const (
+	fileSchemaStr = "file://"
+	stdinTarget   = "-"
+	stdinPath     = "/dev/stdin"
 )
 
-const fileSchemaStr = "file://"
+func IsStdinTarget(target string) bool {
+	return target == stdinTarget || target == fileSchemaStr+stdinPath
+}
+
+func readCertificateData(path string) ([]byte, error) {
+	// Reading stdin directly also works on platforms without /dev/stdin
+	if path == stdinTarget || path == stdinPath {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+func isPEMData(data []byte) bool {
+	return bytes.Contains(data, []byte("-----BEGIN"))
+}
+
+// Handles both single DER certificates and concatenated DER certificates
+func decodeDERCertificates(data []byte, debug bool) ([]*x509.Certificate, error) {
+	if debug {
+		log.Printf("Decoding DER...")
+	}
 
-func loadCertificates(path string, debug bool) ([]*x509.Certificate, error) {
-	bytes, err := ioutil.ReadFile(path)
+	certs, err := x509.ParseCertificates(data)
 	if err != nil {
-		return nil, err
+		return nil, errors.New(fmt.Sprintf("failed to decode DER certificate data: %s",
+			err.Error()))
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in DER data")
 	}
 
 	if debug {
-		log.Printf("Loaded %d bytes from %s", len(bytes), path)
+		for _, cert := range certs {
+			log.Printf("Certificate parsed for '%s'", cert.Subject)
+		}
+	}
 
-		log.Printf("Decoding PEM...")
+	return certs, nil
+}
+
+// OpenSSL's 'TRUSTED CERTIFICATE' blocks have trust settings appended to the certificate
+func parseTrustedCertificate(data []byte) (*x509.Certificate, error) {
+	var certData asn1.RawValue
+	if _, err := asn1.Unmarshal(data, &certData); err != nil {
+		return nil, err
 	}
 
+	return x509.ParseCertificate(certData.FullBytes)
+}
+
+// Decodes every certificate block in the data, skipping over any other blocks (keys, CSRs,
+// etc.) and surrounding text (e.g. 'Bag Attributes' emitted by 'openssl pkcs12')
+func decodePEMCertificates(data []byte, debug bool) ([]*x509.Certificate, error) {
 	certs := []*x509.Certificate{}
 
+	rest := data
 	for {
-		block, rest := pem.Decode(bytes)
+		blockStart := len(data) - len(rest)
+		if beginIdx := bytes.Index(rest, []byte("-----BEGIN")); beginIdx >= 0 {
+			blockStart += beginIdx
+		}
+
+		block, remainder := pem.Decode(rest)
 		if block == nil {
-			return nil, errors.New("failed to decode PEM block containing public key")
+			if debug && len(bytes.TrimSpace(rest)) > 0 {
+				log.Printf("Ignoring %d bytes of non-PEM data at the end of the input", len(rest))
+			}
+
+			break
 		}
+		rest = remainder
 
+		line := bytes.Count(data[:blockStart], []byte("\n")) + 1
 		if debug {
-			log.Printf("Block was decoded (%s)", block.Type)
+			log.Printf("Block was decoded (%s) at line %d", block.Type, line)
 		}
 
-		if block.Type != "CERTIFICATE" {
-			return nil, errors.New("a key did not have the expected 'CERTIFICATE' header")
+		var cert *x509.Certificate
+		var err error
+		switch block.Type {
+		case encoding.PKCS7PEMType:
+			bundleCerts, err := decodePKCS7Certificates(block.Bytes, debug)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("failed to parse '%s' block at line %d: %s",
+					block.Type,
+					line,
+					err.Error()))
+			}
+
+			certs = append(certs, bundleCerts...)
+
+			continue
+		case "CERTIFICATE":
+			cert, err = x509.ParseCertificate(block.Bytes)
+		case "TRUSTED CERTIFICATE":
+			cert, err = parseTrustedCertificate(block.Bytes)
+		default:
+			if debug {
+				log.Printf("Skipping '%s' block at line %d", block.Type, line)
+			}
+
+			continue
 		}
 
-		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("failed to parse '%s' block at line %d: %s",
+				block.Type,
+				line,
+				err.Error()))
+		}
+
+		if debug {
+			log.Printf("Certificate parsed for '%s' at line %d", cert.Subject, line)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no 'CERTIFICATE' or 'PKCS7' PEM blocks were found")
+	}
+
+	if debug {
+		log.Printf("Finished reading the PEM data (%d certificate(s) found)", len(certs))
+	}
+
+	return certs, nil
+}
+
+// PKCS#7 bundles are unordered so the certificates are returned in chain order
+func decodePKCS7Certificates(data []byte, debug bool) ([]*x509.Certificate, error) {
+	if debug {
+		log.Printf("Decoding PKCS#7...")
+	}
+
+	rawCerts, err := encoding.DecodePKCS7(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rawCerts) == 0 {
+		return nil, errors.New("no certificates found in PKCS#7 data")
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for idx, rawCert := range rawCerts {
+		cert, err := x509.ParseCertificate(rawCert)
 		if err != nil {
 			return nil, err
 		}
@@ -49,40 +179,78 @@ func loadCertificates(path string, debug bool) ([]*x509.Certificate, error) {
 			log.Printf("Certificate parsed for '%s'", cert.Subject)
 		}
 
-		certs = append(certs, cert)
+		certs[idx] = cert
+	}
+
+	return orderCertificateChain(certs), nil
+}
+
+func loadCertificates(path string, options Options) ([]*x509.Certificate, error) {
+	debug := options.Debug
+
+	data, err := readCertificateData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if debug {
+		log.Printf("Loaded %d bytes from %s", len(data), path)
+	}
+
+	if !isPEMData(data) {
+		if isPKCS12Data(path, data) {
+			if debug {
+				log.Printf("Decoding PKCS#12...")
+			}
 
-		bytes = rest
+			return loadPKCS12Certificates(data, options)
+		}
 
-		if len(bytes) == 0 {
+		if encoding.IsJKS(data) {
 			if debug {
-				log.Printf("Finished reading the PEM file")
+				log.Printf("Decoding Java keystore...")
 			}
 
-			break
+			return loadJKSCertificates(data, options)
+		}
+
+		if encoding.IsPKCS7(data) {
+			return decodePKCS7Certificates(data, debug)
 		}
+
+		return decodeDERCertificates(data, debug)
 	}
 
-	return certs, nil
+	if debug {
+		log.Printf("Decoding PEM...")
+	}
+
+	return decodePEMCertificates(data, debug)
 }
 
 // TODO Use a specialized logger
-func GetFileCertificates(target string, debug bool) ([]*x509.Certificate, string, error) {
+func GetFileCertificates(target string, options Options) ([]*x509.Certificate, string, error) {
+	debug := options.Debug
 	if debug {
 		log.Printf("Resolving '%s'...", target)
 	}
 
-	path := strings.TrimLeft(target, fileSchemaStr)
+	path := strings.TrimPrefix(target, fileSchemaStr)
 
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return nil, "", err
-	}
+	if !IsStdinTarget(target) {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, "", err
+		}
 
-	if debug {
-		log.Printf("Absolute path is %s", absPath)
+		if debug {
+			log.Printf("Absolute path is %s", absPath)
+		}
+
+		path = absPath
 	}
 
-	certs, err := loadCertificates(absPath, debug)
+	certs, err := loadCertificates(path, options)
 	if err != nil {
 		return nil, "", err
 	}
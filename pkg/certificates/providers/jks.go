@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"crypto/x509"
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/sgnn7/crtool/pkg/encoding"
+)
+
+const jksSchemaStr = "jks://"
+
+func loadJKSCertificates(data []byte, options Options) ([]*x509.Certificate, error) {
+	password, err := options.KeystorePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	if password == "" {
+		log.Printf("WARNING: no keystore password specified - integrity of the keystore " +
+			"was not verified")
+	}
+
+	entries, err := encoding.DecodeJKS(data, password)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := []*x509.Certificate{}
+	for _, entry := range entries {
+		if options.Debug {
+			log.Printf("Keystore entry '%s' (%s, created %s) with %d certificate(s)",
+				entry.Alias,
+				entry.Type,
+				entry.CreationDate.Format("2006-01-02"),
+				len(entry.Certificates))
+		}
+
+		for _, rawCert := range entry.Certificates {
+			cert, err := x509.ParseCertificate(rawCert)
+			if err != nil {
+				return nil, err
+			}
+
+			if options.Debug {
+				log.Printf("Certificate parsed for '%s'", cert.Subject)
+			}
+
+			certs = append(certs, cert)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in keystore")
+	}
+
+	return certs, nil
+}
+
+func GetJKSCertificates(target string, options Options) ([]*x509.Certificate, string, error) {
+	if options.Debug {
+		log.Printf("Resolving '%s'...", target)
+	}
+
+	path := strings.TrimPrefix(target, jksSchemaStr)
+	if path != stdinTarget {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, "", err
+		}
+		path = absPath
+	}
+
+	data, err := readCertificateData(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if options.Debug {
+		log.Printf("Loaded %d bytes from %s", len(data), path)
+		log.Printf("Decoding Java keystore...")
+	}
+
+	certs, err := loadJKSCertificates(data, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return certs, certs[0].Subject.CommonName, nil
+}
@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const pkcs12SchemaStr = "p12://"
+
+// https://tools.ietf.org/html/rfc7292#section-4
+type pfxHeader struct {
+	Version  int
+	AuthSafe asn1.RawValue
+	MacData  asn1.RawContent `asn1:"optional"`
+}
+
+func isPKCS12Data(path string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".p12", ".pfx":
+		return true
+	}
+
+	var header pfxHeader
+	if _, err := asn1.Unmarshal(data, &header); err != nil {
+		return false
+	}
+
+	return header.Version == 3
+}
+
+// Password of keystore targets and outputs, read from the password file if one is set
+func (options Options) KeystorePassword() (string, error) {
+	if options.PasswordFile == "" {
+		return options.Password, nil
+	}
+
+	password, err := ioutil.ReadFile(options.PasswordFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(password), "\r\n"), nil
+}
+
+// Decodes a PKCS#12 (PFX) keystore into its certificates and (optional) private key. If a
+// private key is present, the certificate that belongs to it is returned first.
+func decodePKCS12(data []byte, password string) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err == nil {
+		return append([]*x509.Certificate{cert}, caCerts...), key, nil
+	}
+
+	// Keystores without a private key (e.g. Java truststores) need to be decoded differently
+	certs, trustStoreErr := pkcs12.DecodeTrustStore(data, password)
+	if trustStoreErr == nil {
+		return certs, nil, nil
+	}
+
+	return nil, nil, errors.New(fmt.Sprintf("could not decode PKCS#12 data: %s", err.Error()))
+}
+
+func GetPKCS12Certificates(target string, options Options) ([]*x509.Certificate, string, error) {
+	if options.Debug {
+		log.Printf("Resolving '%s'...", target)
+	}
+
+	path := strings.TrimPrefix(strings.TrimPrefix(target, pkcs12SchemaStr), fileSchemaStr)
+	if path != stdinTarget {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, "", err
+		}
+		path = absPath
+	}
+
+	data, err := readCertificateData(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if options.Debug {
+		log.Printf("Loaded %d bytes from %s", len(data), path)
+		log.Printf("Decoding PKCS#12...")
+	}
+
+	certs, err := loadPKCS12Certificates(data, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return certs, certs[0].Subject.CommonName, nil
+}
+
+func loadPKCS12Certificates(data []byte, options Options) ([]*x509.Certificate, error) {
+	password, err := options.KeystorePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	certs, key, err := decodePKCS12(data, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in PKCS#12 data")
+	}
+
+	if options.Debug {
+		log.Printf("Decoded %d certificate(s) (private key present: %v)", len(certs), key != nil)
+		for _, cert := range certs {
+			log.Printf("Certificate parsed for '%s'", cert.Subject)
+		}
+	}
+
+	return orderCertificateChain(certs), nil
+}
@@ -1,20 +1,111 @@
 package providers
 
 import (
+	"context"
 	"crypto/x509"
 	"log"
+	"net"
 	"strings"
+	"time"
 )
 
+type Options struct {
+	Debug bool
+
+	// Suppresses output that is only useful to interactive commands (e.g. the acceptable CA
+	// names of client certificate requests) unless debugging
+	Quiet bool
+
+	// Plaintext protocol to negotiate before the TLS handshake (e.g. 'smtp')
+	StartTLS string
+
+	// Name to send via SNI (and to verify against) instead of the target's hostname
+	ServerName string
+
+	// Address ('host[:port]') to dial instead of the target's address
+	ConnectAddress string
+
+	// HTTP CONNECT ('http://', 'https://') or SOCKS5 ('socks5://', 'socks5h://') proxy URL.
+	// If empty, HTTPS_PROXY, ALL_PROXY, and NO_PROXY environment variables are used.
+	Proxy string
+
+	// Client identity to present if the server requests one. The certificate file can be a
+	// PEM certificate (with the key in ClientKeyFile or in the same file) or a PKCS#12 keystore.
+	ClientCertFile     string
+	ClientKeyFile      string
+	ClientCertPassword string
+
+	// Password (or file containing it) of keystore targets (e.g. PKCS#12 or JKS)
+	Password     string
+	PasswordFile string
+
+	// Upper bound for each stage of a connection (dial, negotiation, and handshake). Zero
+	// means no timeout.
+	Timeout time.Duration
+}
+
+// Splits a 'host:port' target whose port takes precedence over the given one. Other targets
+// (e.g. URLs, 'file://' and bare IPv6 addresses) are returned with the given port.
+func SplitTargetPort(target string, port string) (string, string) {
+	if strings.Contains(target, "://") {
+		return target, port
+	}
+
+	host, targetPort, err := net.SplitHostPort(target)
+	if err != nil {
+		return target, port
+	}
+
+	return host, targetPort
+}
+
+// Whether the certificates of the target are retrieved over the network ('host[:port]',
+// 'https://' or STARTTLS URLs) instead of being read from files, keystores or stdin
+func IsNetworkTarget(target string) bool {
+	if target == "" || IsStdinTarget(target) {
+		return false
+	}
+
+	schemaIdx := strings.Index(target, "://")
+	if schemaIdx < 0 {
+		return true
+	}
+
+	schema := target[:schemaIdx]
+	return schema == "https" || IsStartTLSProtocol(schema)
+}
+
 // TODO: Use logger instead of debug flag
-func GetCertificates(target string, port string, debug bool) ([]*x509.Certificate, string, error) {
-	if strings.HasPrefix(target, "file://") {
-		if debug {
+func GetCertificates(
+	ctx context.Context,
+	target string,
+	port string,
+	options Options,
+) ([]*x509.Certificate, string, error) {
+
+	if strings.HasPrefix(target, pkcs12SchemaStr) {
+		if options.Debug {
+			log.Printf("Using PKCS#12 cert provider to resolve '%s'", target)
+		}
+
+		return GetPKCS12Certificates(target, options)
+	}
+
+	if strings.HasPrefix(target, jksSchemaStr) {
+		if options.Debug {
+			log.Printf("Using JKS cert provider to resolve '%s'", target)
+		}
+
+		return GetJKSCertificates(target, options)
+	}
+
+	if strings.HasPrefix(target, fileSchemaStr) || IsStdinTarget(target) {
+		if options.Debug {
 			log.Printf("Using file cert provider to resolve '%s'", target)
 		}
 
-		return GetFileCertificates(target, debug)
+		return GetFileCertificates(target, options)
 	}
 
-	return GetTLSCertificates(target, port, debug)
+	return GetTLSCertificates(ctx, target, port, options)
 }
@@ -0,0 +1,417 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProxySchemeHTTP    = "http"
+	ProxySchemeHTTPS   = "https"
+	ProxySchemeSOCKS5  = "socks5"
+	ProxySchemeSOCKS5H = "socks5h"
+)
+
+var defaultProxyPorts = map[string]string{
+	ProxySchemeHTTP:    "80",
+	ProxySchemeHTTPS:   "443",
+	ProxySchemeSOCKS5:  "1080",
+	ProxySchemeSOCKS5H: "1080",
+}
+
+// https://tools.ietf.org/html/rfc1928
+const (
+	socks5Version            = 0x05
+	socks5AuthNone           = 0x00
+	socks5AuthPassword       = 0x02
+	socks5AuthNoAcceptable   = 0xff
+	socks5AuthPasswordStatus = 0x01
+	socks5CommandConnect     = 0x01
+	socks5AddressIPv4        = 0x01
+	socks5AddressDomain      = 0x03
+	socks5AddressIPv6        = 0x04
+	socks5ReplySuccess       = 0x00
+)
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func parseProxyURL(proxy string) (*url.URL, error) {
+	// Bare 'host:port' values are treated as HTTP proxies like most tools do
+	if !strings.Contains(proxy, "://") {
+		proxy = ProxySchemeHTTP + "://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid proxy URL '%s': %s", proxy, err.Error()))
+	}
+
+	if _, ok := defaultProxyPorts[proxyURL.Scheme]; !ok {
+		return nil, errors.New(fmt.Sprintf("proxy scheme '%s' is not supported", proxyURL.Scheme))
+	}
+
+	if proxyURL.Hostname() == "" {
+		return nil, errors.New(fmt.Sprintf("proxy URL '%s' has no host", proxy))
+	}
+
+	return proxyURL, nil
+}
+
+// Checks the host against a NO_PROXY-style list ('*', 'example.com', '.example.com',
+// 'host:port', IPs, and CIDR ranges)
+func isProxyBypassed(noProxy string, hostport string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	hostIP := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		if entry == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if hostIP != nil && network.Contains(hostIP) {
+				return true
+			}
+			continue
+		}
+
+		if entryHost, entryPort, err := net.SplitHostPort(entry); err == nil {
+			if entryPort != port {
+				continue
+			}
+			entry = entryHost
+		}
+
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Determines the proxy to use for a connection to 'hostport'. An explicitly configured proxy
+// takes precedence over the environment (HTTPS_PROXY/HTTP_PROXY, ALL_PROXY, and NO_PROXY).
+func lookupProxy(proxy string, scheme string, hostport string) (*url.URL, error) {
+	if proxy != "" {
+		return parseProxyURL(proxy)
+	}
+
+	if isProxyBypassed(getEnvAny("NO_PROXY", "no_proxy"), hostport) {
+		return nil, nil
+	}
+
+	envProxy := ""
+	switch scheme {
+	case "https":
+		envProxy = getEnvAny("HTTPS_PROXY", "https_proxy")
+	case "http":
+		envProxy = getEnvAny("HTTP_PROXY", "http_proxy")
+	}
+
+	if envProxy == "" {
+		envProxy = getEnvAny("ALL_PROXY", "all_proxy")
+	}
+
+	if envProxy == "" {
+		return nil, nil
+	}
+
+	return parseProxyURL(envProxy)
+}
+
+// Returns a proxy selector for http.Transport that honors the same proxy configuration as
+// the TLS certificate provider
+func HTTPProxyFunc(options Options) func(*http.Request) (*url.URL, error) {
+	return func(request *http.Request) (*url.URL, error) {
+		proxyURL, err := lookupProxy(options.Proxy, request.URL.Scheme, request.URL.Host)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+
+		// Transports only accept 'socks5h' since Go 1.22 but already resolve names on the
+		// proxy with 'socks5'
+		if proxyURL.Scheme == ProxySchemeSOCKS5H {
+			socksURL := *proxyURL
+			socksURL.Scheme = ProxySchemeSOCKS5
+			return &socksURL, nil
+		}
+
+		return proxyURL, nil
+	}
+}
+
+func dialEndpoint(ctx context.Context, address string, options Options) (net.Conn, error) {
+	proxyURL, err := lookupProxy(options.Proxy, "https", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL == nil {
+		return dialTCP(ctx, address, options.Timeout)
+	}
+
+	if options.Debug {
+		log.Printf("Using proxy '%s://%s'...", proxyURL.Scheme, proxyURL.Host)
+	}
+
+	proxyAddress := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddress = net.JoinHostPort(proxyURL.Hostname(), defaultProxyPorts[proxyURL.Scheme])
+	}
+
+	conn, err := dialTCP(ctx, proxyAddress, options.Timeout)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("could not connect to proxy '%s': %s", proxyAddress,
+			err.Error()))
+	}
+
+	proxyConn := conn
+	err = runConnectionStage(ctx, proxyConn, StageProxy, proxyAddress, options.Timeout,
+		func() error {
+			var tunnelErr error
+			switch proxyURL.Scheme {
+			case ProxySchemeSOCKS5, ProxySchemeSOCKS5H:
+				tunnelErr = connectSOCKS5(ctx, proxyConn, proxyURL, address)
+			default:
+				conn, tunnelErr = connectHTTPTunnel(proxyConn, proxyURL, address)
+			}
+
+			return tunnelErr
+		})
+
+	if err != nil {
+		conn.Close()
+		return nil, errors.New(fmt.Sprintf("proxy '%s' could not tunnel to '%s': %s",
+			proxyAddress,
+			address,
+			err.Error()))
+	}
+
+	return conn, nil
+}
+
+// Any data the server sends right after the tunnel is established (e.g. an SMTP greeting)
+// may already be buffered by the response reader so reads must drain that buffer first
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (conn *bufferedConn) Read(data []byte) (int, error) {
+	return conn.reader.Read(data)
+}
+
+// https://tools.ietf.org/html/rfc7231#section-4.3.6
+func connectHTTPTunnel(conn net.Conn, proxyURL *url.URL, address string) (net.Conn, error) {
+	// Unlike the target's, the proxy's certificate is verified as the proxy credentials are
+	// sent over this connection
+	if proxyURL.Scheme == ProxySchemeHTTPS {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName: proxyURL.Hostname(),
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return conn, errors.New(fmt.Sprintf("TLS handshake with proxy failed: %s", err))
+		}
+		conn = tlsConn
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := proxyURL.User.Username() + ":" + password
+		request.Header.Set("Proxy-Authorization",
+			"Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	if err := request.Write(conn); err != nil {
+		return conn, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return conn, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return conn, errors.New(fmt.Sprintf("CONNECT failed with '%s'", response.Status))
+	}
+
+	return &bufferedConn{
+		Conn:   conn,
+		reader: reader,
+	}, nil
+}
+
+func connectSOCKS5(ctx context.Context, conn net.Conn, proxyURL *url.URL, address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid port '%s'", portStr))
+	}
+
+	// Method negotiation
+	methods := []byte{socks5AuthNone}
+	if proxyURL.User != nil {
+		methods = append(methods, socks5AuthPassword)
+	}
+
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	selection := make([]byte, 2)
+	if _, err := io.ReadFull(conn, selection); err != nil {
+		return err
+	}
+
+	switch selection[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if err := authenticateSOCKS5(conn, proxyURL.User); err != nil {
+			return err
+		}
+	case socks5AuthNoAcceptable:
+		return errors.New("no acceptable SOCKS5 authentication methods")
+	default:
+		return errors.New(fmt.Sprintf("unsupported SOCKS5 authentication method 0x%02x",
+			selection[1]))
+	}
+
+	// Plain 'socks5' resolves names locally while 'socks5h' lets the proxy resolve them
+	if proxyURL.Scheme == ProxySchemeSOCKS5 && net.ParseIP(host) == nil {
+		ips, err := lookupIPs(ctx, host, 0)
+		if err != nil {
+			return err
+		}
+		host = ips[0].String()
+	}
+
+	request := []byte{socks5Version, socks5CommandConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			request = append(append(request, socks5AddressIPv4), ipv4...)
+		} else {
+			request = append(append(request, socks5AddressIPv6), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New(fmt.Sprintf("hostname '%s' is too long", host))
+		}
+		request = append(append(request, socks5AddressDomain, byte(len(host))), host...)
+	}
+
+	portBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(portBytes, uint16(port))
+	request = append(request, portBytes...)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	// Reply is version, status, reserved, and the bound address which we discard
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+
+	if reply[1] != socks5ReplySuccess {
+		return errors.New(fmt.Sprintf("SOCKS5 connect failed with status 0x%02x", reply[1]))
+	}
+
+	boundAddressLength := 0
+	switch reply[3] {
+	case socks5AddressIPv4:
+		boundAddressLength = net.IPv4len
+	case socks5AddressIPv6:
+		boundAddressLength = net.IPv6len
+	case socks5AddressDomain:
+		domainLength := make([]byte, 1)
+		if _, err := io.ReadFull(conn, domainLength); err != nil {
+			return err
+		}
+		boundAddressLength = int(domainLength[0])
+	default:
+		return errors.New(fmt.Sprintf("unsupported SOCKS5 address type 0x%02x", reply[3]))
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, boundAddressLength+2))
+	return err
+}
+
+// https://tools.ietf.org/html/rfc1929
+func authenticateSOCKS5(conn net.Conn, user *url.Userinfo) error {
+	if user == nil {
+		return errors.New("SOCKS5 proxy requires credentials")
+	}
+
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("SOCKS5 credentials are too long")
+	}
+
+	request := []byte{socks5AuthPasswordStatus, byte(len(username))}
+	request = append(request, username...)
+	request = append(request, byte(len(password)))
+	request = append(request, password...)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	response := make([]byte, 2)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return err
+	}
+
+	if response[1] != 0x00 {
+		return errors.New("SOCKS5 authentication failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+const (
+	StartTLSNone = ""
+	StartTLSSMTP = "smtp"
+	StartTLSIMAP = "imap"
+	StartTLSPOP3 = "pop3"
+
+	StartTLSPostgres = "postgres"
+	StartTLSMySQL    = "mysql"
+
+	StartTLSLDAP = "ldap"
+	StartTLSXMPP = "xmpp"
+)
+
+const (
+	defaultTLSPort   = "443"
+	imapCommandTag   = "crtool1"
+	smtpClientDomain = "crtool.localhost"
+)
+
+type startTLSNegotiator func(conn net.Conn, hostname string, debug bool) error
+
+var startTLSDefaultPorts = map[string]string{
+	StartTLSSMTP: "25",
+	StartTLSIMAP: "143",
+	StartTLSPOP3: "110",
+
+	StartTLSPostgres: "5432",
+	StartTLSMySQL:    "3306",
+
+	StartTLSLDAP: "389",
+	StartTLSXMPP: "5222",
+}
+
+var startTLSNegotiators = map[string]startTLSNegotiator{
+	StartTLSSMTP: negotiateSMTP,
+	StartTLSIMAP: negotiateIMAP,
+	StartTLSPOP3: negotiatePOP3,
+
+	StartTLSPostgres: negotiatePostgres,
+	StartTLSMySQL:    negotiateMySQL,
+
+	StartTLSLDAP: negotiateLDAP,
+	StartTLSXMPP: negotiateXMPP,
+}
+
+func IsStartTLSProtocol(protocol string) bool {
+	_, ok := startTLSNegotiators[protocol]
+	return ok
+}
+
+func ValidateStartTLSProtocol(protocol string) error {
+	if protocol == StartTLSNone || IsStartTLSProtocol(protocol) {
+		return nil
+	}
+
+	supported := make([]string, 0, len(startTLSNegotiators))
+	for name := range startTLSNegotiators {
+		supported = append(supported, name)
+	}
+	sort.Strings(supported)
+
+	return errors.New(fmt.Sprintf("STARTTLS protocol '%s' is not supported (supported: %s)",
+		protocol,
+		strings.Join(supported, ", ")))
+}
+
+func defaultPortForProtocol(protocol string) string {
+	if port, ok := startTLSDefaultPorts[protocol]; ok {
+		return port
+	}
+
+	return defaultTLSPort
+}
+
+func negotiateStartTLS(protocol string, conn net.Conn, hostname string, debug bool) error {
+	negotiator, ok := startTLSNegotiators[protocol]
+	if !ok {
+		return ValidateStartTLSProtocol(protocol)
+	}
+
+	if debug {
+		log.Printf("Negotiating STARTTLS using '%s' protocol...", protocol)
+	}
+
+	if err := negotiator(conn, hostname, debug); err != nil {
+		// Wrapped so that timeouts can still be detected by the caller
+		return fmt.Errorf("%s STARTTLS negotiation failed: %w", protocol, err)
+	}
+
+	if debug {
+		log.Printf("STARTTLS negotiation complete")
+	}
+
+	return nil
+}
+
+// https://tools.ietf.org/html/rfc3207
+func negotiateSMTP(conn net.Conn, hostname string, debug bool) error {
+	text := textproto.NewConn(conn)
+
+	_, greeting, err := text.ReadResponse(220)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		log.Printf("SMTP greeting: %s", greeting)
+	}
+
+	if err := text.PrintfLine("EHLO %s", smtpClientDomain); err != nil {
+		return err
+	}
+
+	_, extensions, err := text.ReadResponse(250)
+	if err != nil {
+		return err
+	}
+
+	if !strings.Contains(strings.ToUpper(extensions), "STARTTLS") {
+		return errors.New("server does not advertise the STARTTLS extension")
+	}
+
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+
+	_, _, err = text.ReadResponse(220)
+	return err
+}
+
+// https://tools.ietf.org/html/rfc3501#section-6.2.1
+func negotiateIMAP(conn net.Conn, hostname string, debug bool) error {
+	text := textproto.NewConn(conn)
+
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		log.Printf("IMAP greeting: %s", greeting)
+	}
+
+	if !strings.HasPrefix(greeting, "* OK") {
+		return errors.New(fmt.Sprintf("unexpected greeting '%s'", greeting))
+	}
+
+	if err := text.PrintfLine("%s STARTTLS", imapCommandTag); err != nil {
+		return err
+	}
+
+	// Untagged responses may precede the tagged completion result
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(line, imapCommandTag+" ") {
+			continue
+		}
+
+		status := strings.TrimPrefix(line, imapCommandTag+" ")
+		if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+			return errors.New(fmt.Sprintf("server rejected STARTTLS: '%s'", status))
+		}
+
+		return nil
+	}
+}
+
+// https://tools.ietf.org/html/rfc2595#section-4
+func negotiatePOP3(conn net.Conn, hostname string, debug bool) error {
+	text := textproto.NewConn(conn)
+
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		log.Printf("POP3 greeting: %s", greeting)
+	}
+
+	if !strings.HasPrefix(greeting, "+OK") {
+		return errors.New(fmt.Sprintf("unexpected greeting '%s'", greeting))
+	}
+
+	if err := text.PrintfLine("STLS"); err != nil {
+		return err
+	}
+
+	response, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(response, "+OK") {
+		return errors.New(fmt.Sprintf("server rejected STLS: '%s'", response))
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+)
+
+// https://tools.ietf.org/html/rfc4511#section-4.14.1
+const (
+	ldapStartTLSOID       = "1.3.6.1.4.1.1466.20037"
+	ldapStartTLSMessageID = 1
+	ldapResultSuccess     = 0
+
+	// Guards against absurd lengths from non-LDAP servers
+	ldapMaxResponseSize = 1 << 20
+)
+
+type ldapExtendedRequest struct {
+	RequestName []byte `asn1:"tag:0"`
+}
+
+type ldapStartTLSRequest struct {
+	MessageID  int
+	ProtocolOp ldapExtendedRequest `asn1:"application,tag:23"`
+}
+
+// Identifier octets of the elements of the response
+const (
+	berSequence   = 0x30
+	berInteger    = 0x02
+	berEnumerated = 0x0a
+
+	// ExtendedResponse is [APPLICATION 24] (constructed)
+	ldapExtendedResponse = 0x78
+)
+
+// Single-byte identifier and contents of a BER-encoded element
+type berElement struct {
+	identifier byte
+	contents   []byte
+}
+
+// Reads a single BER-encoded element (identifier, length, and contents). Unlike DER, BER
+// allows long-form lengths with leading zeros which e.g. Active Directory sends.
+func readBERElement(reader io.Reader) (berElement, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return berElement{}, err
+	}
+
+	length := int(header[1])
+	if length&0x80 != 0 {
+		numOfLengthBytes := length & 0x7f
+		if numOfLengthBytes == 0 || numOfLengthBytes > 4 {
+			return berElement{}, errors.New("unsupported ASN.1 length encoding")
+		}
+
+		lengthBytes := make([]byte, numOfLengthBytes)
+		if _, err := io.ReadFull(reader, lengthBytes); err != nil {
+			return berElement{}, err
+		}
+
+		length = 0
+		for _, lengthByte := range lengthBytes {
+			length = length<<8 | int(lengthByte)
+		}
+	}
+
+	if length > ldapMaxResponseSize {
+		return berElement{}, errors.New(fmt.Sprintf("response is too large (%d bytes)", length))
+	}
+
+	contents := make([]byte, length)
+	if _, err := io.ReadFull(reader, contents); err != nil {
+		return berElement{}, err
+	}
+
+	return berElement{
+		identifier: header[0],
+		contents:   contents,
+	}, nil
+}
+
+// Reads the next element of a parsed element's contents, checking its identifier
+func readExpectedBERElement(reader io.Reader, identifier byte, name string) (berElement, error) {
+	element, err := readBERElement(reader)
+	if err != nil {
+		return berElement{}, errors.New(fmt.Sprintf("could not parse %s: %s", name, err.Error()))
+	}
+
+	if element.identifier != identifier {
+		return berElement{}, errors.New(fmt.Sprintf("unexpected %s (identifier 0x%02x)", name,
+			element.identifier))
+	}
+
+	return element, nil
+}
+
+func berInt(contents []byte) (int, error) {
+	if len(contents) == 0 || len(contents) > 4 {
+		return 0, errors.New(fmt.Sprintf("unsupported integer length (%d bytes)", len(contents)))
+	}
+
+	value := int(int8(contents[0]))
+	for _, valueByte := range contents[1:] {
+		value = value<<8 | int(valueByte)
+	}
+
+	return value, nil
+}
+
+func negotiateLDAP(conn net.Conn, hostname string, debug bool) error {
+	request, err := asn1.Marshal(ldapStartTLSRequest{
+		MessageID: ldapStartTLSMessageID,
+		ProtocolOp: ldapExtendedRequest{
+			RequestName: []byte(ldapStartTLSOID),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	// Read errors are returned as is so that timeouts can still be detected
+	message, err := readBERElement(conn)
+	if err != nil {
+		return err
+	}
+
+	if message.identifier != berSequence {
+		return errors.New(fmt.Sprintf("unexpected response (identifier 0x%02x)",
+			message.identifier))
+	}
+
+	messageReader := bytes.NewReader(message.contents)
+	if _, err := readExpectedBERElement(messageReader, berInteger, "message ID"); err != nil {
+		return err
+	}
+
+	response, err := readExpectedBERElement(messageReader, ldapExtendedResponse,
+		"response operation")
+	if err != nil {
+		return err
+	}
+
+	responseReader := bytes.NewReader(response.contents)
+	resultCodeElement, err := readExpectedBERElement(responseReader, berEnumerated,
+		"result code")
+	if err != nil {
+		return err
+	}
+
+	resultCode, err := berInt(resultCodeElement.contents)
+	if err != nil {
+		return errors.New(fmt.Sprintf("could not parse result code: %s", err.Error()))
+	}
+
+	if debug {
+		log.Printf("LDAP StartTLS result code: %d", resultCode)
+	}
+
+	if resultCode != ldapResultSuccess {
+		// The matched DN precedes the diagnostic message
+		diagnosticMessage := ""
+		if _, err := readBERElement(responseReader); err == nil {
+			if diagnostic, err := readBERElement(responseReader); err == nil {
+				diagnosticMessage = string(diagnostic.contents)
+			}
+		}
+
+		return errors.New(fmt.Sprintf("server rejected StartTLS (result code %d: '%s')",
+			resultCode,
+			diagnosticMessage))
+	}
+
+	return nil
+}
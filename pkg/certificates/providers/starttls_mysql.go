@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+)
+
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_packets.html
+const (
+	mysqlHeaderSize          = 4
+	mysqlProtocolVersion     = 10
+	mysqlErrorPacketMarker   = 0xff
+	mysqlMaxPacketSize       = 1<<24 - 1
+	mysqlCharsetUTF8         = 33
+	mysqlClientProtocol41    = 0x00000200
+	mysqlClientSSL           = 0x00000800
+	mysqlClientSecureConnect = 0x00008000
+)
+
+func readMySQLPacket(conn net.Conn) ([]byte, byte, error) {
+	header := make([]byte, mysqlHeaderSize)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, 0, err
+	}
+
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	sequence := header[3]
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return nil, 0, err
+	}
+
+	return payload, sequence, nil
+}
+
+func writeMySQLPacket(conn net.Conn, payload []byte, sequence byte) error {
+	length := len(payload)
+	packet := append([]byte{byte(length), byte(length >> 8), byte(length >> 16), sequence}, payload...)
+
+	_, err := conn.Write(packet)
+	return err
+}
+
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_packets_protocol_handshake_v10.html
+func parseMySQLCapabilities(handshake []byte) (string, uint32, error) {
+	if len(handshake) == 0 {
+		return "", 0, errors.New("empty handshake packet")
+	}
+
+	if handshake[0] == mysqlErrorPacketMarker {
+		// Error packets have a 2-byte error code before the message
+		message := ""
+		if len(handshake) > 3 {
+			message = string(handshake[3:])
+		}
+		return "", 0, errors.New(fmt.Sprintf("server returned an error: '%s'", message))
+	}
+
+	if handshake[0] != mysqlProtocolVersion {
+		return "", 0, errors.New(fmt.Sprintf("unsupported protocol version %d", handshake[0]))
+	}
+
+	versionEnd := bytes.IndexByte(handshake[1:], 0)
+	if versionEnd < 0 {
+		return "", 0, errors.New("malformed server version in handshake packet")
+	}
+	serverVersion := string(handshake[1 : 1+versionEnd])
+
+	// Skip protocol version, server version, connection ID (4), auth data (8) and filler (1)
+	offset := 1 + versionEnd + 1 + 4 + 8 + 1
+	if len(handshake) < offset+2 {
+		return "", 0, errors.New("handshake packet is too short")
+	}
+
+	capabilities := uint32(binary.LittleEndian.Uint16(handshake[offset : offset+2]))
+
+	// Skip lower capabilities (2), charset (1) and status flags (2)
+	offset += 2 + 1 + 2
+	if len(handshake) >= offset+2 {
+		capabilities |= uint32(binary.LittleEndian.Uint16(handshake[offset:offset+2])) << 16
+	}
+
+	return serverVersion, capabilities, nil
+}
+
+func negotiateMySQL(conn net.Conn, hostname string, debug bool) error {
+	handshake, sequence, err := readMySQLPacket(conn)
+	if err != nil {
+		return err
+	}
+
+	serverVersion, capabilities, err := parseMySQLCapabilities(handshake)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		log.Printf("MySQL server version: %s", serverVersion)
+	}
+
+	if capabilities&mysqlClientSSL == 0 {
+		return errors.New("server does not support SSL connections")
+	}
+
+	// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_packets_protocol_ssl_request.html
+	sslRequest := make([]byte, 32)
+	binary.LittleEndian.PutUint32(sslRequest[0:4],
+		mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnect)
+	binary.LittleEndian.PutUint32(sslRequest[4:8], mysqlMaxPacketSize)
+	sslRequest[8] = mysqlCharsetUTF8
+
+	return writeMySQLPacket(conn, sslRequest, sequence+1)
+}
@@ -0,0 +1,36 @@
+package providers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// https://www.postgresql.org/docs/current/protocol-message-formats.html (SSLRequest)
+const postgresSSLRequestCode = 80877103
+
+func negotiatePostgres(conn net.Conn, hostname string, debug bool) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], uint32(len(request)))
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequestCode)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return err
+	}
+
+	switch response[0] {
+	case 'S':
+		return nil
+	case 'N':
+		return errors.New("server does not accept SSL connections")
+	}
+
+	return errors.New(fmt.Sprintf("unexpected SSLRequest response '0x%02x'", response[0]))
+}
@@ -0,0 +1,94 @@
+package providers
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// https://tools.ietf.org/html/rfc6120#section-5
+const (
+	xmppStreamNamespace = "http://etherx.jabber.org/streams"
+	xmppTLSNamespace    = "urn:ietf:params:xml:ns:xmpp-tls"
+
+	xmppStreamHeader = "<?xml version='1.0'?>" +
+		"<stream:stream to='%s' xmlns='jabber:client' " +
+		"xmlns:stream='" + xmppStreamNamespace + "' version='1.0'>"
+	xmppStartTLSRequest = "<starttls xmlns='" + xmppTLSNamespace + "'/>"
+)
+
+func negotiateXMPP(conn net.Conn, hostname string, debug bool) error {
+	var escapedHostname strings.Builder
+	if err := xml.EscapeText(&escapedHostname, []byte(hostname)); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, xmppStreamHeader, escapedHostname.String()); err != nil {
+		return err
+	}
+
+	decoder := xml.NewDecoder(conn)
+
+	// Find the STARTTLS advertisement inside of <stream:features/>
+	inFeatures := false
+	startTLSAdvertised := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if element, ok := token.(xml.StartElement); ok {
+			switch {
+			case element.Name.Space == xmppStreamNamespace && element.Name.Local == "error":
+				return errors.New("server returned a stream error")
+			case element.Name.Space == xmppStreamNamespace && element.Name.Local == "features":
+				inFeatures = true
+			case inFeatures && element.Name.Space == xmppTLSNamespace &&
+				element.Name.Local == "starttls":
+
+				startTLSAdvertised = true
+			}
+		}
+
+		if element, ok := token.(xml.EndElement); ok && inFeatures &&
+			element.Name.Space == xmppStreamNamespace && element.Name.Local == "features" {
+
+			break
+		}
+	}
+
+	if !startTLSAdvertised {
+		return errors.New("server does not advertise the STARTTLS feature")
+	}
+
+	if debug {
+		log.Printf("XMPP server advertised STARTTLS")
+	}
+
+	if _, err := conn.Write([]byte(xmppStartTLSRequest)); err != nil {
+		return err
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Space != xmppTLSNamespace {
+			continue
+		}
+
+		switch element.Name.Local {
+		case "proceed":
+			return nil
+		case "failure":
+			return errors.New("server rejected STARTTLS")
+		}
+	}
+}
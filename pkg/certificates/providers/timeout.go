@@ -0,0 +1,139 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	StageResolve   = "DNS lookup"
+	StageDial      = "dial"
+	StageProxy     = "proxy tunnel"
+	StageStartTLS  = "STARTTLS negotiation"
+	StageHandshake = "TLS handshake"
+)
+
+// Identifies which stage of retrieving certificates stalled
+type StageTimeoutError struct {
+	Stage    string
+	Address  string
+	Duration time.Duration
+}
+
+func (err *StageTimeoutError) Error() string {
+	if err.Duration > 0 {
+		return fmt.Sprintf("%s (%s) timed out after %s", err.Stage, err.Address, err.Duration)
+	}
+
+	return fmt.Sprintf("%s (%s) timed out", err.Stage, err.Address)
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Converts timeouts and cancellations of a connection stage into errors naming that stage
+func stageError(ctx context.Context, stage string, address string, timeout time.Duration,
+	err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return errors.New(fmt.Sprintf("%s (%s) was canceled", stage, address))
+	}
+
+	if isTimeout(err) || ctx.Err() != nil {
+		return &StageTimeoutError{
+			Stage:    stage,
+			Address:  address,
+			Duration: timeout,
+		}
+	}
+
+	return err
+}
+
+// Runs a stage of the exchange on an established connection, bounding it by the per-stage
+// timeout and aborting it if the context is done
+func runConnectionStage(
+	ctx context.Context,
+	conn net.Conn,
+	stage string,
+	address string,
+	timeout time.Duration,
+	stageFunc func() error,
+) error {
+
+	deadline, hasDeadline := ctx.Deadline()
+	if timeout > 0 {
+		stageDeadline := time.Now().Add(timeout)
+		if !hasDeadline || stageDeadline.Before(deadline) {
+			deadline = stageDeadline
+			hasDeadline = true
+		}
+	}
+
+	if hasDeadline {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	// Unblock any pending I/O if the context is done while the stage is running
+	stageDone := make(chan struct{})
+	defer close(stageDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-stageDone:
+		}
+	}()
+
+	return stageError(ctx, stage, address, timeout, stageFunc())
+}
+
+func lookupIPs(ctx context.Context, host string, timeout time.Duration) ([]net.IP, error) {
+	lookupCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		lookupCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(lookupCtx, host)
+	if err != nil {
+		return nil, stageError(lookupCtx, StageResolve, host, timeout, err)
+	}
+
+	ips := make([]net.IP, len(addrs))
+	for idx, addr := range addrs {
+		ips[idx] = addr.IP
+	}
+
+	return ips, nil
+}
+
+func dialTCP(ctx context.Context, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{
+		Timeout: timeout,
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, stageError(ctx, StageDial, address, timeout, err)
+	}
+
+	return conn, nil
+}
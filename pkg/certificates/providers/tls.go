@@ -1,62 +1,202 @@
 package providers
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/url"
-	"strings"
 )
 
 var InsecureTLSConfig = &tls.Config{
 	InsecureSkipVerify: true,
 }
 
-func composeEndpoint(host string, port string) (string, string, error) {
+type endpoint struct {
+	hostname string
+	address  string
+	protocol string
+}
+
+func composeEndpoint(host string, port string, options Options) (endpoint, error) {
 	if host == "" {
-		return "", "", errors.New("host not specified!")
+		return endpoint{}, errors.New("host not specified!")
 	}
 
-	if port == "" {
-		return "", "", errors.New("port not specified!")
-	}
+	protocol := options.StartTLS
 
 	// Try to strip off the schema/path/port if someone used a URL
 	url, err := url.ParseRequestURI(host)
-	if err == nil {
-		hostPort := strings.Split(url.Host, ":")
-		if len(hostPort) < 2 {
-			hostPort = append(hostPort, "443")
+	if err == nil && url.Host != "" {
+		if IsStartTLSProtocol(url.Scheme) {
+			if protocol != StartTLSNone && protocol != url.Scheme {
+				return endpoint{}, errors.New(fmt.Sprintf(
+					"STARTTLS protocol '%s' conflicts with target schema '%s://'",
+					protocol,
+					url.Scheme))
+			}
+
+			protocol = url.Scheme
+		}
+
+		host = url.Hostname()
+		if url.Port() != "" {
+			port = url.Port()
+		}
+	}
+
+	if err := ValidateStartTLSProtocol(protocol); err != nil {
+		return endpoint{}, err
+	}
+
+	if port == "" {
+		port = defaultPortForProtocol(protocol)
+	}
+
+	address := net.JoinHostPort(host, port)
+	if options.ConnectAddress != "" {
+		address = options.ConnectAddress
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, port)
 		}
+	}
 
-		return hostPort[0], hostPort[0] + ":" + hostPort[1], nil
+	// The SNI name is what the certificate is expected to be valid for
+	hostname := host
+	if options.ServerName != "" {
+		hostname = options.ServerName
 	}
 
-	return host, net.JoinHostPort(host, port), nil
+	return endpoint{
+		hostname: hostname,
+		address:  address,
+		protocol: protocol,
+	}, nil
 }
 
 // TODO Use a specialized logger
-func GetTLSCertificates(target string, port string, debug bool) ([]*x509.Certificate, string, error) {
-	hostname, endpoint, err := composeEndpoint(target, port)
+func GetTLSCertificates(
+	ctx context.Context,
+	target string,
+	port string,
+	options Options,
+) ([]*x509.Certificate, string, error) {
+
+	endpoint, err := composeEndpoint(target, port, options)
 	if err != nil {
 		return nil, "", err
 	}
 
-	if debug {
-		log.Printf("Dialing '%s'...", endpoint)
+	if options.Debug {
+		log.Printf("Dialing '%s' (SNI: '%s')...", endpoint.address, endpoint.hostname)
 	}
 
-	conn, err := tls.Dial("tcp", endpoint, InsecureTLSConfig)
+	rawConn, err := dialEndpoint(ctx, endpoint.address, options)
 	if err != nil {
 		return nil, "", err
 	}
-	defer conn.Close()
+	defer rawConn.Close()
+
+	if endpoint.protocol != StartTLSNone {
+		err = runConnectionStage(ctx, rawConn, StageStartTLS, endpoint.address, options.Timeout,
+			func() error {
+				return negotiateStartTLS(endpoint.protocol, rawConn, endpoint.hostname,
+					options.Debug)
+			})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	tlsConfig := InsecureTLSConfig.Clone()
+	tlsConfig.ServerName = endpoint.hostname
 
-	if debug {
+	clientCertRequest, err := configureClientCertificate(tlsConfig, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	conn := tls.Client(rawConn, tlsConfig)
+	err = runConnectionStage(ctx, rawConn, StageHandshake, endpoint.address, options.Timeout,
+		conn.Handshake)
+	if !options.Quiet || options.Debug {
+		clientCertRequest.log()
+	}
+	if err != nil {
+		if clientCertRequest.requested && options.ClientCertFile == "" {
+			return nil, "", errors.New(fmt.Sprintf(
+				"%s (server may require a client certificate - see --client-cert)", err.Error()))
+		}
+
+		return nil, "", err
+	}
+
+	if options.Debug {
 		log.Printf("Connection established")
 	}
 
-	return conn.ConnectionState().PeerCertificates, hostname, nil
+	return conn.ConnectionState().PeerCertificates, endpoint.hostname, nil
+}
+
+type AddressCertificates struct {
+	Address      string
+	Certificates []*x509.Certificate
+	Err          error
+}
+
+// Retrieves the certificates served by every IPv4 and IPv6 address of the target using the
+// same SNI name. Per-address connection failures are recorded in the results instead of
+// aborting the whole operation.
+func GetTLSCertificatesForAllAddresses(
+	ctx context.Context,
+	target string,
+	port string,
+	options Options,
+) ([]AddressCertificates, string, error) {
+
+	if options.ConnectAddress != "" {
+		return nil, "", errors.New("probing all addresses cannot be combined with a connect address")
+	}
+
+	endpoint, err := composeEndpoint(target, port, options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	host, resolvedPort, err := net.SplitHostPort(endpoint.address)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if options.Debug {
+		log.Printf("Resolving '%s'...", host)
+	}
+
+	ips, err := lookupIPs(ctx, host, options.Timeout)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if options.Debug {
+		log.Printf("Resolved %d address(es): %v", len(ips), ips)
+	}
+
+	results := make([]AddressCertificates, len(ips))
+	for idx, ip := range ips {
+		addressOptions := options
+		addressOptions.ConnectAddress = net.JoinHostPort(ip.String(), resolvedPort)
+		addressOptions.ServerName = endpoint.hostname
+
+		certs, _, err := GetTLSCertificates(ctx, target, port, addressOptions)
+		results[idx] = AddressCertificates{
+			Address:      addressOptions.ConnectAddress,
+			Certificates: certs,
+			Err:          err,
+		}
+	}
+
+	return results, endpoint.hostname, nil
 }
@@ -2,11 +2,14 @@ package validation
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"time"
@@ -37,20 +40,92 @@ const (
 
 	// https://tools.ietf.org/html/rfc5280#section-5.1.2.6
 	ValidationTypeOCSPRevocation ValidationType = 7
+
+	// https://tools.ietf.org/html/rfc6125#section-6
+	ValidationTypeHostname ValidationType = 8
+
+	// https://tools.ietf.org/html/rfc5280#section-6
+	ValidationTypeChain ValidationType = 9
+
+	// Retrieval of the chain from one of the addresses of a target
+	ValidationTypeConnection ValidationType = 10
+
+	// All addresses of a target serve the same chain
+	ValidationTypeChainConsistency ValidationType = 11
+)
+
+// Names of validation types in machine-readable output which must not change
+var validationTypeNames = map[ValidationType]string{
+	ValidationTypeSubject:          "subject",
+	ValidationTypeNotBefore:        "not_before",
+	ValidationTypeNotAfter:         "not_after",
+	ValidationTypeIssuer:           "issuer",
+	ValidationTypeCACert:           "ca_cert",
+	ValidationTypeBasicContstraint: "basic_constraint",
+	ValidationTypeCRLRevocation:    "crl_revocation",
+	ValidationTypeOCSPRevocation:   "ocsp_revocation",
+	ValidationTypeHostname:         "hostname",
+	ValidationTypeChain:            "chain",
+	ValidationTypeConnection:       "connection",
+	ValidationTypeChainConsistency: "chain_consistency",
+}
+
+var validationTypeDescriptions = map[ValidationType]string{
+	ValidationTypeSubject:          "Certificate subject is valid",
+	ValidationTypeNotBefore:        "Certificate validity period has started",
+	ValidationTypeNotAfter:         "Certificate has not expired",
+	ValidationTypeIssuer:           "Certificate is issued and signed by the next certificate of the chain",
+	ValidationTypeCACert:           "Certificate is a CA certificate",
+	ValidationTypeBasicContstraint: "Certificate has valid basic constraints",
+	ValidationTypeCRLRevocation:    "Certificate is not revoked by a CRL",
+	ValidationTypeOCSPRevocation:   "Certificate is not revoked according to OCSP",
+	ValidationTypeHostname:         "Leaf certificate is valid for the hostname",
+	ValidationTypeChain:            "Chain is trusted by the system CA store",
+	ValidationTypeConnection:       "Chain could be retrieved from the address",
+	ValidationTypeChainConsistency: "All addresses of the target serve the same chain",
+}
+
+func (validationType ValidationType) Description() string {
+	return validationTypeDescriptions[validationType]
+}
+
+func (validationType ValidationType) String() string {
+	if name, ok := validationTypeNames[validationType]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(validationType))
+}
+
+const (
+	StatusPass = "pass"
+	StatusSkip = "skip"
+	StatusWarn = "warn"
+	StatusFail = "fail"
 )
 
 var ValidationResultPass = ValidationResult{
 	ResultStr: " OK ",
+	Status:    StatusPass,
 	Success:   true,
 }
 
 var ValidationResultSkip = ValidationResult{
 	ResultStr: "----",
+	Status:    StatusSkip,
+	Success:   true,
+}
+
+// Passed validations that need attention soon (e.g. certs that are about to expire)
+var ValidationResultWarn = ValidationResult{
+	ResultStr: "WARN",
+	Status:    StatusWarn,
 	Success:   true,
 }
 
 var ValidationResultFail = ValidationResult{
 	ResultStr: "FAIL",
+	Status:    StatusFail,
 	Success:   false,
 }
 
@@ -59,7 +134,11 @@ var ocspOpts = ocsp.RequestOptions{
 }
 
 type ValidationResult struct {
+	// Set by the caller that ran the validation
+	Type ValidationType
+
 	ResultStr string
+	Status    string
 	Message   string
 	Success   bool
 }
@@ -127,6 +206,34 @@ func ValidateNotAfter(notAfter time.Time) (ValidationResult, error) {
 	return ValidationResultPass, nil
 }
 
+// Like ValidateNotAfter but also fails if the cert expires within critDays days and warns if
+// it expires within warnDays days (thresholds of 0 are disabled)
+func ValidateNotAfterThresholds(notAfter time.Time, warnDays int, critDays int) (ValidationResult, error) {
+	result, err := ValidateNotAfter(notAfter)
+	if err != nil || !result.Success {
+		return result, err
+	}
+
+	remaining := time.Until(notAfter)
+	remainingDays := int(remaining.Hours() / 24)
+
+	if critDays > 0 && remaining < time.Duration(critDays)*24*time.Hour {
+		failure := ValidationResultFail
+		failure.Message = fmt.Sprintf("notAfter: cert expires in %d day(s) which is within "+
+			"the critical threshold of %d day(s)", remainingDays, critDays)
+		return failure, nil
+	}
+
+	if warnDays > 0 && remaining < time.Duration(warnDays)*24*time.Hour {
+		warning := ValidationResultWarn
+		warning.Message = fmt.Sprintf("notAfter: cert expires in %d day(s) which is within "+
+			"the warning threshold of %d day(s)", remainingDays, warnDays)
+		return warning, nil
+	}
+
+	return result, nil
+}
+
 func ValidateBasicConstraint(cert x509.Certificate) (ValidationResult, error) {
 	if !cert.BasicConstraintsValid {
 		failure := ValidationResultFail
@@ -178,22 +285,64 @@ func ValidateChain(certs []*x509.Certificate) (ValidationResult, error) {
 	return ValidationResultPass, nil
 }
 
-func downloadFile(url string) ([]byte, error) {
-	response, err := http.Get(url)
+// Names the stalled fetch in timeout and cancellation errors
+func fetchError(
+	ctx context.Context,
+	client *http.Client,
+	fetchType string,
+	url string,
+	err error,
+) error {
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return errors.New(fmt.Sprintf("%s fetch from '%s' was canceled", fetchType, url))
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		if client.Timeout > 0 {
+			return errors.New(fmt.Sprintf("%s fetch from '%s' timed out after %s",
+				fetchType,
+				url,
+				client.Timeout))
+		}
+
+		return errors.New(fmt.Sprintf("%s fetch from '%s' timed out", fetchType, url))
+	}
+
+	return err
+}
+
+func downloadFile(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fetchError(ctx, client, "CRL", url, err)
+	}
 	defer response.Body.Close()
 
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(response.Body)
+	if _, err := buf.ReadFrom(response.Body); err != nil {
+		return nil, fetchError(ctx, client, "CRL", url, err)
+	}
 
 	return buf.Bytes(), nil
 }
 
-func ValidateCRLRevocation(cert x509.Certificate, crlEndpoints []string) (ValidationResult, error) {
+// Fetches the CRLs with the client (e.g. configured to route requests via a proxy)
+func ValidateCRLRevocation(
+	ctx context.Context,
+	client *http.Client,
+	cert x509.Certificate,
+	crlEndpoints []string,
+) (ValidationResult, error) {
+
 	for _, crlEndpoint := range crlEndpoints {
-		crlListBuf, err := downloadFile(crlEndpoint)
+		crlListBuf, err := downloadFile(ctx, client, crlEndpoint)
 		if err != nil {
 			failure := ValidationResultFail
 			failure.Message = err.Error()
@@ -220,12 +369,15 @@ func ValidateCRLRevocation(cert x509.Certificate, crlEndpoints []string) (Valida
 }
 
 func sendOCSPRequest(
+	ctx context.Context,
+	client *http.Client,
 	ocspServer string,
 	ocspRequest []byte,
 	issuer *x509.Certificate,
 ) (*ocsp.Response, error) {
 
-	request, err := http.NewRequest(http.MethodPost, ocspServer, bytes.NewReader(ocspRequest))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, ocspServer,
+		bytes.NewReader(ocspRequest))
 	if err != nil {
 		return nil, err
 	}
@@ -238,17 +390,15 @@ func sendOCSPRequest(
 	request.Header.Add("Host", ocspUrl.Host)
 	request.Header.Add("Content-Type", "application/ocsp-request")
 	request.Header.Add("Accept", "application/ocsp-response")
-	httpClient := &http.Client{}
-
-	response, err := httpClient.Do(request)
+	response, err := client.Do(request)
 	if err != nil {
-		return nil, err
+		return nil, fetchError(ctx, client, "OCSP", ocspServer, err)
 	}
 	defer response.Body.Close()
 
 	responseData, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return nil, err
+		return nil, fetchError(ctx, client, "OCSP", ocspServer, err)
 	}
 
 	ocspResponse, err := ocsp.ParseResponse(responseData, issuer)
@@ -259,7 +409,10 @@ func sendOCSPRequest(
 	return ocspResponse, nil
 }
 
+// Sends the OCSP requests with the client (e.g. configured to route requests via a proxy)
 func ValidateOCSPRevocation(
+	ctx context.Context,
+	client *http.Client,
 	cert *x509.Certificate,
 	issuer *x509.Certificate,
 	ocspServers []string) (ValidationResult, error) {
@@ -277,7 +430,7 @@ func ValidateOCSPRevocation(
 	}
 
 	for _, ocspServer := range ocspServers {
-		ocspResponse, err := sendOCSPRequest(ocspServer, ocspRequest, issuer)
+		ocspResponse, err := sendOCSPRequest(ctx, client, ocspServer, ocspRequest, issuer)
 		if err != nil {
 			failure := ValidationResultFail
 			failure.Message = err.Error()
@@ -1,33 +1,128 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"path"
+	"strings"
+	"time"
 
+	certProviders "github.com/sgnn7/crtool/pkg/certificates/providers"
+	"github.com/sgnn7/crtool/pkg/diff"
 	"github.com/sgnn7/crtool/pkg/encoding"
+	"github.com/sgnn7/crtool/pkg/exporter"
+	"github.com/sgnn7/crtool/pkg/report"
+	"github.com/sgnn7/crtool/pkg/scan"
 	"github.com/sgnn7/crtool/pkg/ssl"
 	"github.com/sgnn7/crtool/pkg/version"
+	"github.com/sgnn7/crtool/pkg/watch"
 )
 
+// Process exit codes compatible with Nagios/Icinga plugins
 const (
+	ExitCodeOK       = 0
+	ExitCodeWarning  = 1
+	ExitCodeCritical = 2
+	ExitCodeError    = 3
+)
+
+const (
+	allIPsDefaultValue     = false
+	allIPsUsage            = "Probe every resolved IPv4 and IPv6 address of the target"
+	chainADefaultValue     = ""
+	chainAUsage            = "First chain to compare (any target supported by -t of the other commands)"
+	chainBDefaultValue     = ""
+	chainBUsage            = "Second chain to compare (any target supported by -t of the other commands)"
+	cidrDefaultValue       = ""
+	cidrUsage              = "Comma-separated CIDR ranges or IP addresses to discover TLS services in (instead of a target list)"
+	clientCertDefaultValue = ""
+	clientCertUsage        = "Client certificate to present (PEM or PKCS#12) if the server requests one"
+	clientKeyDefaultValue  = ""
+	clientKeyUsage         = "Private key (PEM) of the client certificate (defaults to the client cert file)"
+	clientPassDefaultValue = ""
+	clientPassUsage        = "Password of the PKCS#12 client certificate"
+	concurrDefaultValue    = scan.DefaultConcurrency
+	concurrUsage           = "Number of targets scanned at the same time"
 	debugDefaultValue      = false
 	debugUsage             = "Enables debug messages"
 	encodingDefaultValue   = "pem"
-	encodingUsage          = "Select type of output encoding ('pem' or 'der')"
+	encodingUsage          = "Select type of output encoding ('pem', 'der', 'p7b' or 'jks')"
+	execDefaultValue       = ""
+	execUsage              = "Shell command run for each event (gets the event as JSON on stdin and in CRTOOL_EVENT_* variables)"
+	fileDefaultValue       = ""
+	fileUsage              = "File with a target ('host[:port]', URL or 'file://') per line, or CSV with target and port columns ('-' for stdin)"
+	formatDefaultValue     = "text"
+	formatUsage            = "Output format ('text', 'json', or for verify 'junit', 'sarif' or 'nagios')"
+	indexDefaultValue      = -1
+	indexUsage             = "Only output the certificate at this position of the chain (0 is the leaf)"
+	intervalDefaultValue   = watch.DefaultInterval
+	intervalUsage          = "Interval between checks of the targets"
+	listenDefaultValue     = exporter.DefaultListenAddress
+	listenUsage            = "Address ('[host]:port') that the exporter listens on"
+	nameTmplDefaultValue   = ""
+	nameTmplUsage          = "File name template of split certificates ('{{.Index}}', '{{.CN}}', '{{.Role}}', '{{.SHA256}}', '{{.Ext}}') (implies --split)"
 	outputFileDefaultValue = ""
 	outputFileUsage        = "Output destination path (defaults to stdout if not specified)"
-	portDefaultValue       = "443"
-	portUsage              = "Destination port"
+	onceDefaultValue       = false
+	onceUsage              = "Check the targets once and exit instead of on every interval"
+	connectDefaultValue    = ""
+	connectUsage           = "Address ('ip[:port]') to connect to instead of the target's address"
+	critDaysDefaultValue   = 0
+	critDaysUsage          = "Fail if a certificate expires within this many days (0 disables it)"
+	passwordDefaultValue   = ""
+	passwordUsage          = "Password of keystore targets and 'jks' outputs (e.g. PKCS#12 or JKS)"
+	passwordFileUsage      = "File containing the password of keystore targets"
+	portDefaultValue       = ""
+	portUsage              = "Destination port (defaults to 443 or the STARTTLS protocol's port)"
+	portsDefaultValue      = "443"
+	portsUsage             = "Comma-separated ports probed on each address of the --cidr ranges"
+	proxyDefaultValue      = ""
+	proxyUsage             = "Proxy URL ('http://', 'https://', 'socks5://' or 'socks5h://') (defaults to $HTTPS_PROXY/$ALL_PROXY)"
+	rateDefaultValue       = 0.0
+	rateUsage              = "Maximum number of targets started per second (0 disables rate limiting)"
+	sniDefaultValue        = ""
+	sniUsage               = "Server name to send via SNI and verify against (defaults to the target)"
+	splitDefaultValue      = false
+	splitUsage             = "Write each certificate to its own 'cert-<index>' file in the output directory"
+	stateFileDefaultValue  = ""
+	stateFileUsage         = "File that keeps the last state of the targets across restarts"
+	startTLSDefaultValue   = ""
+	startTLSUsage          = "STARTTLS protocol ('smtp', 'imap', 'pop3', 'postgres', 'mysql', 'ldap' or 'xmpp')"
+	timeoutDefaultValue    = 30 * time.Second
+	timeoutUsage           = "Timeout for each connection stage and CRL/OCSP request (0 disables it)"
 	targetDefaultValue     = ""
-	targetUsage            = "Destination IP or DNS name of the target"
+	tgtTimeoutDefaultValue = scan.DefaultTargetTimeout
+	tgtTimeoutUsage        = "Timeout for retrieving and validating each target (0 disables it)"
+	targetsDefaultValue    = ""
+	targetsUsage           = "Comma-separated targets ('host', 'host:port' or URLs) to monitor"
+	targetUsage            = "Destination IP or DNS name of the target, optionally with ':port' (or 'file://', 'p12://', 'jks://', or '-' for stdin)"
 	versionUsage           = "Show program version"
+	warnDaysDefaultValue   = 0
+	warnDaysUsage          = "Warn if a certificate expires within this many days (0 disables it)"
+	webhookDefaultValue    = ""
+	webhookUsage           = "URL that events are posted to as JSON"
 )
 
+// Maps the result of running crtool to its process exit code
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCodeOK
+	case errors.Is(err, ssl.ErrValidationWarning):
+		return ExitCodeWarning
+	case errors.Is(err, ssl.ErrValidationFailed):
+		return ExitCodeCritical
+	}
+
+	return ExitCodeError
+}
+
 func HandleOutput(output string, options ssl.Options) error {
 	if options.Debug {
 		log.Println("Handling action output...")
@@ -42,10 +137,77 @@ func HandleOutput(output string, options ssl.Options) error {
 		return ioutil.WriteFile(options.OutputFile, []byte(output), 0640)
 	}
 
-	fmt.Printf(output)
+	fmt.Print(output)
 	return nil
 }
 
+// Commands that take their targets in other flags pass a nil target
+func addConnectionFlags(
+	command *flag.FlagSet,
+	target *string,
+	port *string,
+	providerOptions *certProviders.Options,
+) {
+
+	if target != nil {
+		command.StringVar(target, "target", targetDefaultValue, targetUsage)
+		command.StringVar(target, "t", targetDefaultValue, targetUsage+" (shorthand)")
+	}
+
+	command.StringVar(port, "port", portDefaultValue, portUsage)
+	command.StringVar(port, "p", portDefaultValue, portUsage+" (shorthand)")
+
+	command.StringVar(&providerOptions.StartTLS, "starttls", startTLSDefaultValue, startTLSUsage)
+
+	command.StringVar(&providerOptions.ServerName, "sni", sniDefaultValue, sniUsage)
+	command.StringVar(&providerOptions.ConnectAddress, "connect", connectDefaultValue, connectUsage)
+
+	command.StringVar(&providerOptions.Proxy, "proxy", proxyDefaultValue, proxyUsage)
+
+	command.StringVar(&providerOptions.ClientCertFile, "client-cert", clientCertDefaultValue,
+		clientCertUsage)
+	command.StringVar(&providerOptions.ClientKeyFile, "client-key", clientKeyDefaultValue,
+		clientKeyUsage)
+	command.StringVar(&providerOptions.ClientCertPassword, "client-cert-password",
+		clientPassDefaultValue, clientPassUsage)
+
+	command.StringVar(&providerOptions.Password, "password", passwordDefaultValue, passwordUsage)
+	command.StringVar(&providerOptions.PasswordFile, "password-file", passwordDefaultValue,
+		passwordFileUsage)
+
+	command.DurationVar(&providerOptions.Timeout, "timeout", timeoutDefaultValue, timeoutUsage)
+
+	command.BoolVar(&providerOptions.Debug, "debug", debugDefaultValue, debugUsage)
+}
+
+// Help requested with '-h' is not an error. Other errors were already printed along with the
+// usage so they are returned as-is to be mapped to the error exit code.
+func parseError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+
+	return err
+}
+
+// Creates a context that is canceled when the user interrupts the program
+func newInterruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(interrupts)
+	}()
+
+	return ctx, cancel
+}
+
 func RunCRTool() error {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
@@ -53,20 +215,56 @@ func RunCRTool() error {
 	}
 
 	var certEncoding,
+		chainA,
+		chainB,
+		cidrs,
+		execCommand,
+		format,
+		listFile,
+		listenAddress,
+		nameTemplate,
 		outputFile,
 		port,
-		target string
-	var debug bool
+		ports,
+		stateFile,
+		target,
+		targets,
+		webhookURL string
+	var allIPs,
+		once,
+		split bool
+	var concurrency,
+		critDays,
+		index,
+		warnDays int
+	var rate float64
+	var interval,
+		targetTimeout time.Duration
+	var providerOptions certProviders.Options
 
-	dumpCommand := flag.NewFlagSet("dump", flag.ExitOnError)
-	verifyCommand := flag.NewFlagSet("verify", flag.ExitOnError)
+	diffCommand := flag.NewFlagSet("diff", flag.ContinueOnError)
+	dumpCommand := flag.NewFlagSet("dump", flag.ContinueOnError)
+	exporterCommand := flag.NewFlagSet("exporter", flag.ContinueOnError)
+	scanCommand := flag.NewFlagSet("scan", flag.ContinueOnError)
+	showCommand := flag.NewFlagSet("show", flag.ContinueOnError)
+	verifyCommand := flag.NewFlagSet("verify", flag.ContinueOnError)
+	watchCommand := flag.NewFlagSet("watch", flag.ContinueOnError)
+
+	// Diff flags
+	addConnectionFlags(diffCommand, nil, &port, &providerOptions)
+
+	diffCommand.StringVar(&chainA, "a", chainADefaultValue, chainAUsage)
+	diffCommand.StringVar(&chainB, "b", chainBDefaultValue, chainBUsage)
+
+	diffCommand.StringVar(&outputFile, "output", outputFileDefaultValue, outputFileUsage)
+	diffCommand.StringVar(&outputFile, "o", outputFileDefaultValue, outputFileUsage+" (shorthand)")
+
+	diffCommand.StringVar(&format, "format", formatDefaultValue, formatUsage)
 
 	// Dump flags
-	dumpCommand.StringVar(&target, "target", targetDefaultValue, targetUsage)
-	dumpCommand.StringVar(&target, "t", targetDefaultValue, targetUsage+" (shorthand)")
+	addConnectionFlags(dumpCommand, &target, &port, &providerOptions)
 
-	dumpCommand.StringVar(&port, "port", portDefaultValue, portUsage)
-	dumpCommand.StringVar(&port, "p", portDefaultValue, portUsage+" (shorthand)")
+	dumpCommand.BoolVar(&allIPs, "all-ips", allIPsDefaultValue, allIPsUsage)
 
 	dumpCommand.StringVar(&outputFile, "output", outputFileDefaultValue, outputFileUsage)
 	dumpCommand.StringVar(&outputFile, "o", outputFileDefaultValue, outputFileUsage+" (shorthand)")
@@ -74,19 +272,78 @@ func RunCRTool() error {
 	dumpCommand.StringVar(&certEncoding, "encoding", encodingDefaultValue, encodingUsage)
 	dumpCommand.StringVar(&certEncoding, "e", encodingDefaultValue, encodingUsage+" (shorthand)")
 
-	dumpCommand.BoolVar(&debug, "debug", debugDefaultValue, debugUsage)
+	dumpCommand.IntVar(&index, "index", indexDefaultValue, indexUsage)
+	dumpCommand.BoolVar(&split, "split", splitDefaultValue, splitUsage)
+	dumpCommand.StringVar(&nameTemplate, "name-template", nameTmplDefaultValue, nameTmplUsage)
+
+	// Exporter flags
+	addConnectionFlags(exporterCommand, &target, &port, &providerOptions)
+
+	exporterCommand.StringVar(&listenAddress, "listen", listenDefaultValue, listenUsage)
+	exporterCommand.StringVar(&targets, "targets", targetsDefaultValue, targetsUsage)
+
+	exporterCommand.IntVar(&warnDays, "warn-days", warnDaysDefaultValue, warnDaysUsage)
+	exporterCommand.IntVar(&critDays, "crit-days", critDaysDefaultValue, critDaysUsage)
+
+	// Scan flags
+	addConnectionFlags(scanCommand, &target, &port, &providerOptions)
+
+	scanCommand.StringVar(&listFile, "file", fileDefaultValue, fileUsage)
+	scanCommand.StringVar(&listFile, "f", fileDefaultValue, fileUsage+" (shorthand)")
+
+	scanCommand.StringVar(&cidrs, "cidr", cidrDefaultValue, cidrUsage)
+	scanCommand.StringVar(&ports, "ports", portsDefaultValue, portsUsage)
+
+	scanCommand.IntVar(&concurrency, "concurrency", concurrDefaultValue, concurrUsage)
+	scanCommand.DurationVar(&targetTimeout, "target-timeout", tgtTimeoutDefaultValue,
+		tgtTimeoutUsage)
+	scanCommand.Float64Var(&rate, "rate", rateDefaultValue, rateUsage)
+
+	scanCommand.StringVar(&outputFile, "output", outputFileDefaultValue, outputFileUsage)
+	scanCommand.StringVar(&outputFile, "o", outputFileDefaultValue, outputFileUsage+" (shorthand)")
+
+	scanCommand.StringVar(&format, "format", formatDefaultValue, formatUsage)
+
+	scanCommand.IntVar(&warnDays, "warn-days", warnDaysDefaultValue, warnDaysUsage)
+	scanCommand.IntVar(&critDays, "crit-days", critDaysDefaultValue, critDaysUsage)
+
+	// Show flags
+	addConnectionFlags(showCommand, &target, &port, &providerOptions)
+
+	showCommand.BoolVar(&allIPs, "all-ips", allIPsDefaultValue, allIPsUsage)
+
+	showCommand.StringVar(&outputFile, "output", outputFileDefaultValue, outputFileUsage)
+	showCommand.StringVar(&outputFile, "o", outputFileDefaultValue, outputFileUsage+" (shorthand)")
+
+	showCommand.StringVar(&format, "format", formatDefaultValue, formatUsage)
 
 	// Verify flags
-	verifyCommand.StringVar(&target, "target", targetDefaultValue, targetUsage)
-	verifyCommand.StringVar(&target, "t", targetDefaultValue, targetUsage+" (shorthand)")
+	addConnectionFlags(verifyCommand, &target, &port, &providerOptions)
 
-	verifyCommand.StringVar(&port, "port", portDefaultValue, portUsage)
-	verifyCommand.StringVar(&port, "p", portDefaultValue, portUsage+" (shorthand)")
+	verifyCommand.BoolVar(&allIPs, "all-ips", allIPsDefaultValue, allIPsUsage)
 
 	verifyCommand.StringVar(&outputFile, "output", outputFileDefaultValue, outputFileUsage)
 	verifyCommand.StringVar(&outputFile, "o", outputFileDefaultValue, outputFileUsage+" (shorthand)")
 
-	verifyCommand.BoolVar(&debug, "debug", debugDefaultValue, debugUsage)
+	verifyCommand.StringVar(&format, "format", formatDefaultValue, formatUsage)
+
+	verifyCommand.IntVar(&warnDays, "warn-days", warnDaysDefaultValue, warnDaysUsage)
+	verifyCommand.IntVar(&critDays, "crit-days", critDaysDefaultValue, critDaysUsage)
+
+	// Watch flags
+	addConnectionFlags(watchCommand, &target, &port, &providerOptions)
+
+	watchCommand.StringVar(&targets, "targets", targetsDefaultValue, targetsUsage)
+
+	watchCommand.DurationVar(&interval, "interval", intervalDefaultValue, intervalUsage)
+	watchCommand.StringVar(&stateFile, "state-file", stateFileDefaultValue, stateFileUsage)
+	watchCommand.BoolVar(&once, "once", onceDefaultValue, onceUsage)
+
+	watchCommand.StringVar(&webhookURL, "webhook", webhookDefaultValue, webhookUsage)
+	watchCommand.StringVar(&execCommand, "exec", execDefaultValue, execUsage)
+
+	watchCommand.IntVar(&warnDays, "warn-days", warnDaysDefaultValue, warnDaysUsage)
+	watchCommand.IntVar(&critDays, "crit-days", critDaysDefaultValue, critDaysUsage)
 
 	if len(os.Args) < 2 {
 		showVersion := flag.Bool("v", false, versionUsage)
@@ -98,20 +355,54 @@ func RunCRTool() error {
 			return nil
 		}
 
-		fmt.Println("verify or dump subcommand is required")
-		os.Exit(1)
+		fmt.Println("verify, dump, show, diff, scan, watch or exporter subcommand is required")
+		os.Exit(ExitCodeError)
 	}
 
-	if debug {
+	if providerOptions.Debug {
 		log.Println("Starting...")
 	}
 
+	ctx, cancel := newInterruptibleContext()
+	defer cancel()
+
 	switch action := os.Args[1]; action {
-	case "dump":
-		dumpCommand.Parse(os.Args[2:])
+	case "diff":
+		if err := diffCommand.Parse(os.Args[2:]); err != nil {
+			return parseError(err)
+		}
+
+		outputFormat, err := report.NewFormatFromStr(format)
+		if err != nil {
+			return err
+		}
+
 		options := ssl.Options{
-			Debug:      debug,
+			Options:    providerOptions,
 			OutputFile: outputFile,
+			Format:     outputFormat,
+		}
+
+		output, err := diff.DiffTargets(ctx, chainA, chainB, port, options)
+		if err != nil {
+			return err
+		}
+
+		return HandleOutput(output, options)
+	case "dump":
+		if err := dumpCommand.Parse(os.Args[2:]); err != nil {
+			return parseError(err)
+		}
+
+		target, port = certProviders.SplitTargetPort(target, port)
+
+		options := ssl.Options{
+			Options:      providerOptions,
+			AllIPs:       allIPs,
+			OutputFile:   outputFile,
+			Index:        index,
+			Split:        split || nameTemplate != "",
+			NameTemplate: nameTemplate,
 		}
 
 		encodingType, err := encoding.NewTypeFromStr(certEncoding)
@@ -119,30 +410,228 @@ func RunCRTool() error {
 			return err
 		}
 
-		output, err := ssl.GetServerCert(target, port, encodingType, options)
+		output, err := ssl.GetServerCert(ctx, target, port, encodingType, options)
 		if err != nil {
 			return err
 		}
 
+		// Split certificates are written to the output directory directly
+		if options.Split {
+			return nil
+		}
+
 		return HandleOutput(output, options)
-	case "verify":
-		verifyCommand.Parse(os.Args[2:])
+	case "exporter":
+		if err := exporterCommand.Parse(os.Args[2:]); err != nil {
+			return parseError(err)
+		}
+
+		options := exporter.Options{
+			Options: ssl.Options{
+				Options:  providerOptions,
+				WarnDays: warnDays,
+				CritDays: critDays,
+			},
+			ListenAddress: listenAddress,
+			Port:          port,
+		}
+
+		if target != "" {
+			options.Targets = append(options.Targets, target)
+		}
+		for _, exporterTarget := range strings.Split(targets, ",") {
+			if exporterTarget = strings.TrimSpace(exporterTarget); exporterTarget != "" {
+				options.Targets = append(options.Targets, exporterTarget)
+			}
+		}
+
+		return exporter.Run(ctx, options)
+	case "scan":
+		if err := scanCommand.Parse(os.Args[2:]); err != nil {
+			return parseError(err)
+		}
+
+		outputFormat, err := report.NewFormatFromStr(format)
+		if err != nil {
+			return err
+		}
+
+		options := scan.Options{
+			Options: ssl.Options{
+				Options:    providerOptions,
+				OutputFile: outputFile,
+				WarnDays:   warnDays,
+				CritDays:   critDays,
+				Format:     outputFormat,
+			},
+			Concurrency:   concurrency,
+			TargetTimeout: targetTimeout,
+			Rate:          rate,
+			Port:          port,
+		}
+
+		if cidrs != "" {
+			if target != "" || listFile != "" {
+				return errors.New("--cidr cannot be combined with a target or target list")
+			}
+
+			scanPorts, err := scan.ParsePorts(ports)
+			if err != nil {
+				return err
+			}
+
+			inventory, err := scan.DiscoverServices(ctx, strings.Split(cidrs, ","), scanPorts,
+				options)
+			if err != nil {
+				return err
+			}
+
+			output, err := inventory.Render(outputFormat)
+			if err != nil {
+				return err
+			}
+
+			return HandleOutput(output, options.Options)
+		}
+
+		targets := []scan.Target{}
+		if target != "" {
+			targets = append(targets, scan.NewTarget(target, ""))
+		}
+		if listFile != "" {
+			listTargets, err := scan.LoadTargets(listFile)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, listTargets...)
+		}
+
+		scanReport, err := scan.ScanTargets(ctx, targets, options)
+		if err != nil {
+			return err
+		}
+
+		output, err := scanReport.Render(outputFormat)
+		if err != nil {
+			return err
+		}
+
+		// Reports are output even if targets failed validation
+		if err := HandleOutput(output, options.Options); err != nil {
+			return err
+		}
+
+		return scanReport.Err()
+	case "show":
+		if err := showCommand.Parse(os.Args[2:]); err != nil {
+			return parseError(err)
+		}
+
+		target, port = certProviders.SplitTargetPort(target, port)
+
+		outputFormat, err := report.NewFormatFromStr(format)
+		if err != nil {
+			return err
+		}
+
 		options := ssl.Options{
-			Debug:      debug,
+			Options:    providerOptions,
+			AllIPs:     allIPs,
 			OutputFile: outputFile,
+			Format:     outputFormat,
 		}
 
-		output, err := ssl.VerifyServerCertChain(target, port, options)
+		output, err := ssl.ShowServerCerts(ctx, target, port, options)
 		if err != nil {
 			return err
 		}
 
 		return HandleOutput(output, options)
+	case "verify":
+		if err := verifyCommand.Parse(os.Args[2:]); err != nil {
+			return parseError(err)
+		}
+
+		target, port = certProviders.SplitTargetPort(target, port)
+
+		outputFormat, err := report.NewFormatFromStr(format)
+		if err != nil {
+			return err
+		}
+
+		options := ssl.Options{
+			Options:    providerOptions,
+			AllIPs:     allIPs,
+			OutputFile: outputFile,
+			WarnDays:   warnDays,
+			CritDays:   critDays,
+			Format:     outputFormat,
+		}
+
+		// Reports are output even if the chain failed validation
+		output, err := ssl.VerifyServerCertChain(ctx, target, port, options)
+		if err != nil && output == "" {
+			switch outputFormat {
+			case report.FormatNagios:
+				// Monitoring systems only read the plugin output
+				output = report.NagiosUnknown(err)
+			case report.FormatJUnit, report.FormatSARIF:
+				// CI systems expect a document even if the chain could not be retrieved
+				failureReport := report.NewConnectionFailure("verify", target, err)
+				failureOutput, renderErr := failureReport.Render(outputFormat)
+				if renderErr != nil {
+					return renderErr
+				}
+				output = failureOutput
+			default:
+				return err
+			}
+		}
+
+		if outputErr := HandleOutput(output, options); outputErr != nil {
+			return outputErr
+		}
+
+		return err
+	case "watch":
+		if err := watchCommand.Parse(os.Args[2:]); err != nil {
+			return parseError(err)
+		}
+
+		options := watch.Options{
+			Options: ssl.Options{
+				Options:  providerOptions,
+				WarnDays: warnDays,
+				CritDays: critDays,
+			},
+			Port:      port,
+			Interval:  interval,
+			StateFile: stateFile,
+			Once:      once,
+		}
+
+		if target != "" {
+			options.Targets = append(options.Targets, scan.NewTarget(target, ""))
+		}
+		for _, watchTarget := range strings.Split(targets, ",") {
+			if watchTarget = strings.TrimSpace(watchTarget); watchTarget != "" {
+				options.Targets = append(options.Targets, scan.NewTarget(watchTarget, ""))
+			}
+		}
+
+		if webhookURL != "" {
+			options.Hooks = append(options.Hooks, watch.NewWebhookHook(webhookURL,
+				providerOptions.Timeout))
+		}
+		if execCommand != "" {
+			options.Hooks = append(options.Hooks, watch.NewExecHook(execCommand,
+				providerOptions.Timeout))
+		}
+
+		return watch.Run(ctx, options)
 	default:
 		flag.PrintDefaults()
-		return errors.New(fmt.Sprintf("action '%s' not supported - only 'dump' and 'verify' are supported",
+		return errors.New(fmt.Sprintf("action '%s' not supported - only 'diff', 'dump', 'exporter', 'scan', 'show', 'verify' and 'watch' are supported",
 			action))
 	}
-
-	return nil
 }
@@ -0,0 +1,358 @@
+package diff
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sgnn7/crtool/pkg/certificates/inspection"
+	certProviders "github.com/sgnn7/crtool/pkg/certificates/providers"
+	"github.com/sgnn7/crtool/pkg/report"
+	"github.com/sgnn7/crtool/pkg/ssl"
+)
+
+// Statuses of the certificates at a position of the chains
+const (
+	StatusIdentical = "identical"
+	StatusChanged   = "changed"
+	StatusAdded     = "added"
+	StatusRemoved   = "removed"
+)
+
+// Change of a field of a certificate. Single-valued fields have the values of both
+// certificates while list fields only have the values that were added or removed.
+type FieldChange struct {
+	Field string `json:"field"`
+
+	A string `json:"a,omitempty"`
+	B string `json:"b,omitempty"`
+
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Differences of the certificates at a position of the chains
+type CertificateDiff struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+
+	SubjectA string `json:"subject_a,omitempty"`
+	SubjectB string `json:"subject_b,omitempty"`
+
+	Changes []FieldChange `json:"changes"`
+}
+
+// Source of a compared chain
+type Source struct {
+	Target       string `json:"target"`
+	Host         string `json:"host"`
+	Certificates int    `json:"certificates"`
+}
+
+type Diff struct {
+	SchemaVersion int    `json:"schema_version"`
+	Command       string `json:"command"`
+
+	A Source `json:"a"`
+	B Source `json:"b"`
+
+	Identical    bool              `json:"identical"`
+	Certificates []CertificateDiff `json:"certificates"`
+}
+
+type scalarField struct {
+	name  string
+	value string
+}
+
+type listField struct {
+	name   string
+	values []string
+}
+
+// Fields are named like those of the JSON output of show
+func scalarFields(details inspection.Details) []scalarField {
+	maxPathLen := "none"
+	if details.MaxPathLen != nil {
+		maxPathLen = fmt.Sprint(*details.MaxPathLen)
+	}
+
+	return []scalarField{
+		{"version", fmt.Sprint(details.Version)},
+		{"serial_number", details.SerialNumber},
+		{"signature_algorithm", details.SignatureAlgorithm},
+		{"subject", details.Subject},
+		{"issuer", details.Issuer},
+		{"not_before", details.NotBefore.Format(time.RFC3339)},
+		{"not_after", details.NotAfter.Format(time.RFC3339)},
+		{"public_key", details.PublicKey},
+		{"is_ca", fmt.Sprint(details.IsCA)},
+		{"max_path_len", maxPathLen},
+		{"subject_key_id", details.SubjectKeyID},
+		{"authority_key_id", details.AuthorityKeyID},
+		{"sha256_fingerprint", details.SHA256Fingerprint},
+	}
+}
+
+func listFields(details inspection.Details, cert *x509.Certificate) []listField {
+	return []listField{
+		{"dns_names", details.DNSNames},
+		{"ip_addresses", details.IPAddresses},
+		{"email_addresses", details.EmailAddresses},
+		{"uris", details.URIs},
+		{"key_usage", details.KeyUsage},
+		{"ext_key_usage", details.ExtKeyUsage},
+		{"name_constraints_permitted", details.NameConstraints.Permitted},
+		{"name_constraints_excluded", details.NameConstraints.Excluded},
+		{"policies", details.Policies},
+		{"ocsp_servers", details.OCSPServers},
+		{"issuing_certificate_urls", details.IssuingCertificateURLs},
+		{"crl_distribution_points", details.CRLDistributionPoints},
+		{"extensions", inspection.ExtensionNames(cert)},
+	}
+}
+
+// Values of the first list that are not in the second one
+func missingValues(values []string, others []string) []string {
+	otherSet := map[string]bool{}
+	for _, other := range others {
+		otherSet[other] = true
+	}
+
+	missing := []string{}
+	for _, value := range values {
+		if !otherSet[value] {
+			missing = append(missing, value)
+		}
+	}
+
+	return missing
+}
+
+func compareCertificates(
+	idx int,
+	certA *x509.Certificate,
+	certB *x509.Certificate,
+) CertificateDiff {
+
+	certDiff := CertificateDiff{
+		Index:   idx,
+		Changes: []FieldChange{},
+	}
+
+	switch {
+	case certB == nil:
+		certDiff.Status = StatusRemoved
+		certDiff.SubjectA = certA.Subject.String()
+		return certDiff
+	case certA == nil:
+		certDiff.Status = StatusAdded
+		certDiff.SubjectB = certB.Subject.String()
+		return certDiff
+	}
+
+	detailsA := inspection.NewDetails(certA)
+	detailsB := inspection.NewDetails(certB)
+	certDiff.SubjectA = detailsA.Subject
+	certDiff.SubjectB = detailsB.Subject
+
+	fieldsB := scalarFields(detailsB)
+	for idx, fieldA := range scalarFields(detailsA) {
+		if fieldA.value != fieldsB[idx].value {
+			certDiff.Changes = append(certDiff.Changes, FieldChange{
+				Field: fieldA.name,
+				A:     fieldA.value,
+				B:     fieldsB[idx].value,
+			})
+		}
+	}
+
+	listsB := listFields(detailsB, certB)
+	for idx, listA := range listFields(detailsA, certA) {
+		added := missingValues(listsB[idx].values, listA.values)
+		removed := missingValues(listA.values, listsB[idx].values)
+		if len(added) > 0 || len(removed) > 0 {
+			certDiff.Changes = append(certDiff.Changes, FieldChange{
+				Field:   listA.name,
+				Added:   added,
+				Removed: removed,
+			})
+		}
+	}
+
+	certDiff.Status = StatusIdentical
+	if len(certDiff.Changes) > 0 {
+		certDiff.Status = StatusChanged
+	}
+
+	return certDiff
+}
+
+// Compares the certificates at each position of the chains
+func CompareChains(certsA []*x509.Certificate, certsB []*x509.Certificate) []CertificateDiff {
+	count := len(certsA)
+	if len(certsB) > count {
+		count = len(certsB)
+	}
+
+	certDiffs := []CertificateDiff{}
+	for idx := 0; idx < count; idx++ {
+		var certA, certB *x509.Certificate
+		if idx < len(certsA) {
+			certA = certsA[idx]
+		}
+		if idx < len(certsB) {
+			certB = certsB[idx]
+		}
+
+		certDiffs = append(certDiffs, compareCertificates(idx, certA, certB))
+	}
+
+	return certDiffs
+}
+
+// Loads a chain through the certificate providers. 'host:port' targets override the port.
+func loadChain(
+	ctx context.Context,
+	name string,
+	target string,
+	port string,
+	options ssl.Options,
+) ([]*x509.Certificate, string, error) {
+
+	source, port := certProviders.SplitTargetPort(target, port)
+
+	certs, host, err := certProviders.GetCertificates(ctx, source, port, options.Options)
+	if err != nil {
+		return nil, "", errors.New(fmt.Sprintf("could not load chain %s: %s", name, err))
+	}
+
+	return certs, host, nil
+}
+
+// Loads both chains through the certificate providers and compares them
+func DiffTargets(
+	ctx context.Context,
+	targetA string,
+	targetB string,
+	port string,
+	options ssl.Options,
+) (string, error) {
+
+	switch options.Format {
+	case "", report.FormatText, report.FormatJSON:
+	default:
+		return "", errors.New(fmt.Sprintf("output format '%s' is not supported by diff",
+			options.Format))
+	}
+
+	if targetA == "" || targetB == "" {
+		return "", errors.New("both chains to compare (-a and -b) are required")
+	}
+
+	certsA, hostA, err := loadChain(ctx, "a", targetA, port, options)
+	if err != nil {
+		return "", err
+	}
+
+	certsB, hostB, err := loadChain(ctx, "b", targetB, port, options)
+	if err != nil {
+		return "", err
+	}
+
+	chainDiff := &Diff{
+		SchemaVersion: report.SchemaVersion,
+		Command:       "diff",
+		A:             Source{Target: targetA, Host: hostA, Certificates: len(certsA)},
+		B:             Source{Target: targetB, Host: hostB, Certificates: len(certsB)},
+		Identical:     true,
+		Certificates:  CompareChains(certsA, certsB),
+	}
+
+	for _, certDiff := range chainDiff.Certificates {
+		if certDiff.Status != StatusIdentical {
+			chainDiff.Identical = false
+		}
+	}
+
+	if options.Format == report.FormatJSON {
+		return chainDiff.JSON()
+	}
+
+	return chainDiff.Text(), nil
+}
+
+func (chainDiff *Diff) JSON() (string, error) {
+	data, err := json.MarshalIndent(chainDiff, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
+
+func textValue(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+
+	return value
+}
+
+// Renders the changed fields of each position of the chains like a unified diff
+func (chainDiff *Diff) Text() string {
+	var output strings.Builder
+
+	fmt.Fprintf(&output, "--- a: %s (%d certificate(s))\n", chainDiff.A.Target,
+		chainDiff.A.Certificates)
+	fmt.Fprintf(&output, "+++ b: %s (%d certificate(s))\n", chainDiff.B.Target,
+		chainDiff.B.Certificates)
+
+	changed := 0
+	for _, certDiff := range chainDiff.Certificates {
+		fmt.Fprintf(&output, "\nCertificate: %d/%d (%s)\n", certDiff.Index+1,
+			len(chainDiff.Certificates), certDiff.Status)
+
+		switch certDiff.Status {
+		case StatusIdentical:
+			fmt.Fprintf(&output, "  %s\n", certDiff.SubjectA)
+			continue
+		case StatusChanged:
+			fmt.Fprintf(&output, "  %s\n", certDiff.SubjectB)
+		case StatusRemoved:
+			fmt.Fprintf(&output, "- %s\n", certDiff.SubjectA)
+		case StatusAdded:
+			fmt.Fprintf(&output, "+ %s\n", certDiff.SubjectB)
+		}
+		changed++
+
+		for _, change := range certDiff.Changes {
+			fmt.Fprintf(&output, "  %s:\n", change.Field)
+			if change.Added == nil && change.Removed == nil {
+				fmt.Fprintf(&output, "  - %s\n", textValue(change.A))
+				fmt.Fprintf(&output, "  + %s\n", textValue(change.B))
+				continue
+			}
+
+			for _, value := range change.Removed {
+				fmt.Fprintf(&output, "  - %s\n", value)
+			}
+			for _, value := range change.Added {
+				fmt.Fprintf(&output, "  + %s\n", value)
+			}
+		}
+	}
+
+	if chainDiff.Identical {
+		output.WriteString("\nChains are identical\n")
+	} else {
+		fmt.Fprintf(&output, "\nChains differ at %d of %d position(s)\n", changed,
+			len(chainDiff.Certificates))
+	}
+
+	return output.String()
+}
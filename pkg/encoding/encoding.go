@@ -13,6 +13,8 @@ const (
 	Unknown EncodingType = -1
 	PEM     EncodingType = 0
 	DER     EncodingType = 1
+	P7B     EncodingType = 2
+	JKS     EncodingType = 3
 )
 
 func NewTypeFromStr(encodingStr string) (EncodingType, error) {
@@ -21,13 +23,39 @@ func NewTypeFromStr(encodingStr string) (EncodingType, error) {
 		return PEM, nil
 	case "der", "DER":
 		return DER, nil
+	case "p7b", "P7B", "pkcs7", "PKCS7":
+		return P7B, nil
+	case "jks", "JKS":
+		return JKS, nil
 	}
 
 	return Unknown,
 		errors.New(fmt.Sprintf("encoding type '%s' is not supported!", encodingStr))
 }
 
+// Conventional file extension (including the dot) for files of the encoding type
+func (encType EncodingType) FileExtension() string {
+	switch encType {
+	case PEM:
+		return ".pem"
+	case DER:
+		return ".der"
+	case P7B:
+		return ".p7b"
+	case JKS:
+		return ".jks"
+	}
+
+	return ""
+}
+
+// Encodes the certificates with the encoding type. DER can only hold a single certificate so
+// only the first one (the leaf) is encoded with it.
 func EncodeCerts(rawCerts [][]byte, encType EncodingType) ([]byte, error) {
+	if len(rawCerts) == 0 {
+		return []byte{}, errors.New("no certificates to encode")
+	}
+
 	var buf bytes.Buffer
 	switch encType {
 	case PEM:
@@ -44,8 +72,12 @@ func EncodeCerts(rawCerts [][]byte, encType EncodingType) ([]byte, error) {
 		return buf.Bytes(), nil
 	case DER:
 		return rawCerts[0], nil
+	case P7B:
+		return EncodePKCS7(rawCerts)
+	case JKS:
+		return EncodeJKS(rawCerts, DefaultJKSPassword)
 	}
 
 	return []byte{},
-		errors.New(fmt.Sprintf("encoding type ID:%s is not supported!", encType))
+		errors.New(fmt.Sprintf("encoding type ID:%d is not supported!", encType))
 }
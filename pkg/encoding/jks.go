@@ -0,0 +1,259 @@
+package encoding
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Format as implemented by sun.security.provider.JavaKeyStore and com.sun.crypto.provider.JceKeyStore
+const (
+	jksMagic   uint32 = 0xfeedfeed
+	jceksMagic uint32 = 0xcececece
+
+	jksVersion1 uint32 = 1
+	jksVersion2 uint32 = 2
+
+	jksTagPrivateKey  uint32 = 1
+	jksTagTrustedCert uint32 = 2
+	jksTagSecretKey   uint32 = 3
+
+	jksCertType       = "X.509"
+	jksIntegritySalt  = "Mighty Aphrodite"
+	jksMaxEntryLength = 1 << 24
+
+	// Default keytool store password
+	DefaultJKSPassword = "changeit"
+)
+
+type JKSEntryType string
+
+const (
+	JKSPrivateKeyEntry  JKSEntryType = "PrivateKeyEntry"
+	JKSTrustedCertEntry JKSEntryType = "trustedCertEntry"
+)
+
+type JKSEntry struct {
+	Alias        string
+	Type         JKSEntryType
+	CreationDate time.Time
+
+	// Trusted cert entries have exactly one certificate while private key entries have their
+	// certificate chain
+	Certificates [][]byte
+}
+
+func IsJKS(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+
+	magic := binary.BigEndian.Uint32(data[0:4])
+	return magic == jksMagic || magic == jceksMagic
+}
+
+func jksPasswordBytes(password string) []byte {
+	passwordBytes := []byte{}
+	for _, char := range utf16.Encode([]rune(password)) {
+		passwordBytes = append(passwordBytes, byte(char>>8), byte(char))
+	}
+
+	return passwordBytes
+}
+
+func jksDigest(password string, data []byte) []byte {
+	hash := sha1.New()
+	hash.Write(jksPasswordBytes(password))
+	hash.Write([]byte(jksIntegritySalt))
+	hash.Write(data)
+
+	return hash.Sum(nil)
+}
+
+type jksReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (reader *jksReader) read(data interface{}) {
+	if reader.err == nil {
+		reader.err = binary.Read(reader.reader, binary.BigEndian, data)
+	}
+}
+
+func (reader *jksReader) readUint32() uint32 {
+	var value uint32
+	reader.read(&value)
+	return value
+}
+
+func (reader *jksReader) readBytes(length uint32) []byte {
+	if reader.err != nil {
+		return nil
+	}
+
+	if length > jksMaxEntryLength {
+		reader.err = errors.New(fmt.Sprintf("entry length %d is too large", length))
+		return nil
+	}
+
+	data := make([]byte, length)
+	_, reader.err = io.ReadFull(reader.reader, data)
+	return data
+}
+
+func (reader *jksReader) readUTF() string {
+	var length uint16
+	reader.read(&length)
+	return string(reader.readBytes(uint32(length)))
+}
+
+func (reader *jksReader) readCertificate(version uint32) []byte {
+	if version == jksVersion2 {
+		if certType := reader.readUTF(); reader.err == nil && certType != jksCertType {
+			reader.err = errors.New(fmt.Sprintf("certificate type '%s' is not supported", certType))
+			return nil
+		}
+	}
+
+	return reader.readBytes(reader.readUint32())
+}
+
+// Parses a JKS or JCEKS keystore. If a password is provided, the integrity of the keystore
+// is verified with it. Private keys are not decrypted since only the certificates are used.
+func DecodeJKS(data []byte, password string) ([]JKSEntry, error) {
+	if !IsJKS(data) {
+		return nil, errors.New("data is not a JKS or JCEKS keystore")
+	}
+
+	if len(data) < sha1.Size {
+		return nil, errors.New("keystore is truncated")
+	}
+
+	contents := data[:len(data)-sha1.Size]
+	if password != "" {
+		if !bytes.Equal(jksDigest(password, contents), data[len(contents):]) {
+			return nil, errors.New("keystore was tampered with, or password was incorrect")
+		}
+	}
+
+	reader := &jksReader{reader: bytes.NewReader(contents)}
+	reader.readUint32() // Magic
+
+	version := reader.readUint32()
+	if reader.err == nil && version != jksVersion1 && version != jksVersion2 {
+		return nil, errors.New(fmt.Sprintf("keystore version %d is not supported", version))
+	}
+
+	numOfEntries := reader.readUint32()
+	entries := []JKSEntry{}
+	for idx := uint32(0); idx < numOfEntries && reader.err == nil; idx++ {
+		tag := reader.readUint32()
+		entry := JKSEntry{
+			Alias: reader.readUTF(),
+		}
+
+		var timestamp int64
+		reader.read(&timestamp)
+		entry.CreationDate = time.Unix(0, timestamp*int64(time.Millisecond))
+
+		switch tag {
+		case jksTagPrivateKey:
+			entry.Type = JKSPrivateKeyEntry
+
+			// Protected key is skipped
+			reader.readBytes(reader.readUint32())
+
+			numOfCerts := reader.readUint32()
+			for certIdx := uint32(0); certIdx < numOfCerts && reader.err == nil; certIdx++ {
+				entry.Certificates = append(entry.Certificates, reader.readCertificate(version))
+			}
+		case jksTagTrustedCert:
+			entry.Type = JKSTrustedCertEntry
+			entry.Certificates = [][]byte{reader.readCertificate(version)}
+		case jksTagSecretKey:
+			// Secret keys are serialized Java objects whose length cannot be determined
+			return nil, errors.New(fmt.Sprintf("secret key entry '%s' is not supported",
+				entry.Alias))
+		default:
+			return nil, errors.New(fmt.Sprintf("keystore entry type %d is not supported", tag))
+		}
+
+		if reader.err == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	if reader.err != nil {
+		return nil, errors.New(fmt.Sprintf("could not parse keystore: %s", reader.err.Error()))
+	}
+
+	return entries, nil
+}
+
+func jksAlias(idx int, rawCert []byte) string {
+	alias := fmt.Sprintf("cert-%d", idx)
+
+	cert, err := x509.ParseCertificate(rawCert)
+	if err == nil && cert.Subject.CommonName != "" {
+		alias = fmt.Sprintf("%d-%s", idx, cert.Subject.CommonName)
+	}
+
+	// Java keystores treat aliases as case-insensitive
+	return strings.ToLower(alias)
+}
+
+type jksWriter struct {
+	buf bytes.Buffer
+}
+
+func (writer *jksWriter) write(data interface{}) {
+	binary.Write(&writer.buf, binary.BigEndian, data)
+}
+
+func (writer *jksWriter) writeUTF(value string) {
+	writer.write(uint16(len(value)))
+	writer.buf.WriteString(value)
+}
+
+// Creates a JKS truststore with a trusted cert entry for each certificate
+func EncodeJKS(rawCerts [][]byte, password string) ([]byte, error) {
+	if len(rawCerts) == 0 {
+		return nil, errors.New("no certificates to encode")
+	}
+
+	if password == "" {
+		password = DefaultJKSPassword
+	}
+
+	writer := &jksWriter{}
+	writer.write(jksMagic)
+	writer.write(jksVersion2)
+	writer.write(uint32(len(rawCerts)))
+
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	for idx, rawCert := range rawCerts {
+		alias := jksAlias(idx, rawCert)
+		if len(alias) > 0xffff {
+			return nil, errors.New(fmt.Sprintf("alias '%s' is too long", alias))
+		}
+
+		writer.write(jksTagTrustedCert)
+		writer.writeUTF(alias)
+		writer.write(timestamp)
+		writer.writeUTF(jksCertType)
+		writer.write(uint32(len(rawCert)))
+		writer.buf.Write(rawCert)
+	}
+
+	writer.buf.Write(jksDigest(password, writer.buf.Bytes()))
+
+	return writer.buf.Bytes(), nil
+}
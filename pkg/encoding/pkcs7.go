@@ -0,0 +1,124 @@
+package encoding
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// https://tools.ietf.org/html/rfc2315#section-14
+var (
+	oidPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+const PKCS7PEMType = "PKCS7"
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7EncapsulatedContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+// https://tools.ietf.org/html/rfc2315#section-9.1
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      pkcs7EncapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+func emptySet() asn1.RawValue {
+	return asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+	}
+}
+
+// Creates a degenerate ("certs-only") PKCS#7 SignedData bundle in DER encoding
+func EncodePKCS7(rawCerts [][]byte) ([]byte, error) {
+	certsBytes := []byte{}
+	for _, cert := range rawCerts {
+		certsBytes = append(certsBytes, cert...)
+	}
+
+	signedData := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: emptySet(),
+		ContentInfo: pkcs7EncapsulatedContentInfo{
+			ContentType: oidPKCS7Data,
+		},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      certsBytes,
+		},
+		SignerInfos: emptySet(),
+	}
+
+	signedDataBytes, err := asn1.Marshal(signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Raw values are marshaled as-is so the explicit tag has to be added here
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidPKCS7SignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      signedDataBytes,
+		},
+	})
+}
+
+func IsPKCS7(data []byte) bool {
+	var contentInfo pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(data, &contentInfo); err != nil {
+		return false
+	}
+
+	return contentInfo.ContentType.Equal(oidPKCS7SignedData)
+}
+
+// Extracts the raw (DER) certificates from a PKCS#7 SignedData bundle in DER encoding
+func DecodePKCS7(data []byte) ([][]byte, error) {
+	var contentInfo pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(data, &contentInfo); err != nil {
+		return nil, errors.New(fmt.Sprintf("could not parse PKCS#7 content info: %s", err.Error()))
+	}
+
+	if !contentInfo.ContentType.Equal(oidPKCS7SignedData) {
+		return nil, errors.New(fmt.Sprintf("PKCS#7 content type '%s' is not supported",
+			contentInfo.ContentType))
+	}
+
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, errors.New(fmt.Sprintf("could not parse PKCS#7 signed data: %s", err.Error()))
+	}
+
+	rawCerts := [][]byte{}
+	rest := signedData.Certificates.Bytes
+	for len(rest) > 0 {
+		var rawCert asn1.RawValue
+		var err error
+		rest, err = asn1.Unmarshal(rest, &rawCert)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("could not parse PKCS#7 certificate: %s",
+				err.Error()))
+		}
+
+		rawCerts = append(rawCerts, rawCert.FullBytes)
+	}
+
+	return rawCerts, nil
+}
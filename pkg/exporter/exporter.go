@@ -0,0 +1,252 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	certProviders "github.com/sgnn7/crtool/pkg/certificates/providers"
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+	"github.com/sgnn7/crtool/pkg/report"
+	"github.com/sgnn7/crtool/pkg/ssl"
+	"github.com/sgnn7/crtool/pkg/version"
+)
+
+const (
+	DefaultListenAddress = ":9793"
+
+	metricsPath = "/metrics"
+	probePath   = "/probe"
+
+	shutdownTimeout = 5 * time.Second
+)
+
+// Values of crtool_verify_status which match the exit codes of verify
+var verifyStatusValues = map[string]float64{
+	validation.StatusPass: 0,
+	validation.StatusWarn: 1,
+	validation.StatusFail: 2,
+}
+
+type Options struct {
+	ssl.Options
+
+	ListenAddress string
+
+	// Targets probed on every scrape of the metrics endpoint ('host', 'host:port' or URLs)
+	Targets []string
+
+	// Port of targets that do not specify one
+	Port string
+}
+
+type probeResult struct {
+	target   string
+	report   *report.Report
+	err      error
+	duration time.Duration
+}
+
+func probe(ctx context.Context, target string, options Options) probeResult {
+	host, port := certProviders.SplitTargetPort(target, options.Port)
+
+	start := time.Now()
+	targetReport, err := ssl.ValidateServerCertChain(ctx, host, port, options.Options)
+	result := probeResult{
+		target:   target,
+		report:   targetReport,
+		err:      err,
+		duration: time.Since(start),
+	}
+
+	if err != nil {
+		log.Printf("Probe of '%s' failed: %s", target, err)
+	} else if options.Debug {
+		log.Printf("Probe of '%s' finished with status '%s' in %s", target, targetReport.Status,
+			result.duration)
+	}
+
+	return result
+}
+
+func probeAll(ctx context.Context, targets []string, options Options) []probeResult {
+	results := make([]probeResult, len(targets))
+
+	var waitGroup sync.WaitGroup
+	for idx, target := range targets {
+		waitGroup.Add(1)
+		go func(idx int, target string) {
+			defer waitGroup.Done()
+			results[idx] = probe(ctx, target, options)
+		}(idx, target)
+	}
+	waitGroup.Wait()
+
+	return results
+}
+
+func addProbeMetrics(metrics *metricSet, result probeResult) {
+	targetLabels := labelSet{"target": result.target}
+
+	success := 0.0
+	if result.err == nil {
+		success = 1
+	}
+	metrics.add("crtool_probe_success", "Whether the certificates of the target were retrieved",
+		targetLabels, success)
+	metrics.add("crtool_probe_duration_seconds",
+		"Duration of retrieving and validating the certificates of the target", targetLabels,
+		result.duration.Seconds())
+
+	if result.err != nil {
+		return
+	}
+
+	metrics.add("crtool_verify_status",
+		"Overall validation status of the chain (0 = pass, 1 = warn, 2 = fail)", targetLabels,
+		verifyStatusValues[result.report.Status])
+
+	addValidationMetrics(metrics, result.target, "", result.report.Validations)
+
+	for _, certificate := range result.report.Certificates {
+		certLabels := labelSet{
+			"target":  result.target,
+			"index":   fmt.Sprint(certificate.Index),
+			"subject": certificate.Subject,
+			"issuer":  certificate.Issuer,
+			"serial":  certificate.SerialNumber,
+		}
+
+		metrics.add("crtool_cert_not_after_seconds",
+			"Expiry time of the certificate in seconds since the epoch", certLabels,
+			float64(certificate.NotAfter.Unix()))
+		metrics.add("crtool_cert_not_before_seconds",
+			"Start time of the certificate's validity in seconds since the epoch", certLabels,
+			float64(certificate.NotBefore.Unix()))
+
+		addValidationMetrics(metrics, result.target, fmt.Sprint(certificate.Index),
+			certificate.Validations)
+	}
+}
+
+// Validations of the chain have an empty index label
+func addValidationMetrics(
+	metrics *metricSet,
+	target string,
+	index string,
+	validations []report.Validation,
+) {
+
+	for _, targetValidation := range validations {
+		success := 1.0
+		if targetValidation.Status == validation.StatusFail {
+			success = 0
+		}
+
+		metrics.add("crtool_validation_success",
+			"Whether the validation passed (skipped and warned validations count as passed)",
+			labelSet{
+				"target": target,
+				"index":  index,
+				"type":   targetValidation.Type,
+			},
+			success)
+	}
+}
+
+func writeMetrics(writer http.ResponseWriter, metrics *metricSet) {
+	writer.Header().Set("Content-Type", metricsContentType)
+	if err := metrics.write(writer); err != nil {
+		log.Printf("Could not write metrics: %s", err)
+	}
+}
+
+func metricsHandler(options Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		metrics := newMetricSet()
+		metrics.add("crtool_exporter_build_info", "Version of the crtool exporter",
+			labelSet{"version": version.FullVersionName}, 1)
+
+		for _, result := range probeAll(request.Context(), options.Targets, options) {
+			addProbeMetrics(metrics, result)
+		}
+
+		writeMetrics(writer, metrics)
+	}
+}
+
+// Probes the target of the request like the Prometheus blackbox exporter
+func probeHandler(options Options) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		target := request.URL.Query().Get("target")
+		if target == "" {
+			http.Error(writer, "'target' parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		// Probes must not read local files or stdin of the exporter
+		if !certProviders.IsNetworkTarget(target) {
+			http.Error(writer, fmt.Sprintf("target '%s' is not a network target", target),
+				http.StatusBadRequest)
+			return
+		}
+
+		metrics := newMetricSet()
+		addProbeMetrics(metrics, probe(request.Context(), target, options))
+		writeMetrics(writer, metrics)
+	}
+}
+
+// Serves the metrics and probe endpoints until the context is done
+func Run(ctx context.Context, options Options) error {
+	listenAddress := options.ListenAddress
+	if listenAddress == "" {
+		listenAddress = DefaultListenAddress
+	}
+
+	for _, target := range options.Targets {
+		if !certProviders.IsNetworkTarget(target) {
+			return errors.New(fmt.Sprintf("target '%s' is not a network target ('host[:port]' "+
+				"or an 'https://' or STARTTLS URL)", target))
+		}
+	}
+
+	// Probes share the client of CRL and OCSP requests and its connections
+	options.ValidationClient = ssl.NewValidationHTTPClient(options.Options)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, metricsHandler(options))
+	mux.HandleFunc(probePath, probeHandler(options))
+
+	server := &http.Server{
+		Addr:    listenAddress,
+		Handler: mux,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	log.Printf("Serving metrics of %d target(s) on %s%s and probes on %s%s",
+		len(options.Targets), listenAddress, metricsPath, listenAddress, probePath)
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type labelSet map[string]string
+
+type sample struct {
+	labels labelSet
+	value  float64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	samples []sample
+}
+
+// Collects gauge samples and writes them in the Prometheus text exposition format. Families
+// are written in the order they were first added so that each has a single HELP/TYPE header.
+type metricSet struct {
+	families []*metricFamily
+	byName   map[string]*metricFamily
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{
+		byName: map[string]*metricFamily{},
+	}
+}
+
+func (metrics *metricSet) add(name string, help string, labels labelSet, value float64) {
+	family, ok := metrics.byName[name]
+	if !ok {
+		family = &metricFamily{
+			name: name,
+			help: help,
+		}
+		metrics.byName[name] = family
+		metrics.families = append(metrics.families, family)
+	}
+
+	family.samples = append(family.samples, sample{
+		labels: labels,
+		value:  value,
+	})
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels labelSet) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for idx, name := range names {
+		pairs[idx] = fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (metrics *metricSet) write(writer io.Writer) error {
+	for _, family := range metrics.families {
+		help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(family.help)
+		if _, err := fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s gauge\n", family.name, help,
+			family.name); err != nil {
+			return err
+		}
+
+		for _, sample := range family.samples {
+			_, err := fmt.Fprintf(writer, "%s%s %s\n", family.name, formatLabels(sample.labels),
+				strconv.FormatFloat(sample.value, 'g', -1, 64))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
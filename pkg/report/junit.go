@@ -0,0 +1,99 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+)
+
+// https://github.com/testmoapp/junitxml
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct{}
+
+// Renders each validation as a test case and each validated scope (the chain and its
+// certificates) as a test suite
+func (report *Report) JUnit() (string, error) {
+	testSuites := junitTestSuites{
+		Name: fmt.Sprintf("crtool %s %s", report.Command, report.Target),
+	}
+
+	for _, scope := range report.scopes() {
+		if len(scope.validations) == 0 {
+			continue
+		}
+
+		testSuite := junitTestSuite{
+			Name: scope.name,
+		}
+
+		for _, scopeValidation := range scope.validations {
+			testCase := junitTestCase{
+				Name:      scopeValidation.Type,
+				ClassName: scope.name,
+			}
+
+			switch scopeValidation.Status {
+			case validation.StatusFail:
+				testCase.Failure = &junitFailure{
+					Message: scopeValidation.Message,
+					Type:    scopeValidation.Type,
+					Text: fmt.Sprintf("%s: %s", scopeValidation.ValidationType.Description(),
+						scopeValidation.Message),
+				}
+				testSuite.Failures++
+			case validation.StatusWarn:
+				// Warnings pass but their message is kept with the test case
+				testCase.SystemOut = fmt.Sprintf("WARN: %s", scopeValidation.Message)
+			case validation.StatusSkip:
+				testCase.Skipped = &junitSkipped{}
+				testSuite.Skipped++
+			}
+
+			testSuite.Tests++
+			testSuite.TestCases = append(testSuite.TestCases, testCase)
+		}
+
+		testSuites.Tests += testSuite.Tests
+		testSuites.Failures += testSuite.Failures
+		testSuites.Skipped += testSuite.Skipped
+		testSuites.Suites = append(testSuites.Suites, testSuite)
+	}
+
+	data, err := xml.MarshalIndent(testSuites, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return xml.Header + string(data) + "\n", nil
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+)
+
+// https://nagios-plugins.org/doc/guidelines.html#PLUGOUTPUT
+const nagiosServiceName = "CRTOOL"
+
+var nagiosStates = map[string]string{
+	validation.StatusPass: "OK",
+	validation.StatusWarn: "WARNING",
+	validation.StatusFail: "CRITICAL",
+}
+
+// Status line of a check that could not be run (e.g. the target could not be reached)
+func NagiosUnknown(err error) string {
+	return fmt.Sprintf("%s UNKNOWN - %s\n", nagiosServiceName, nagiosText(err.Error()))
+}
+
+// Pipes separate the performance data and line breaks separate long output
+func nagiosText(text string) string {
+	return strings.NewReplacer("|", "/", "\n", " ").Replace(text)
+}
+
+func daysLeft(notAfter time.Time) int {
+	return int(time.Until(notAfter).Hours() / 24)
+}
+
+func nagiosThreshold(days int) string {
+	if days <= 0 {
+		return ""
+	}
+
+	return fmt.Sprint(days)
+}
+
+func (report *Report) nagiosPerfData(label string, certificate Certificate) string {
+	// Labels with anything but letters, digits and underscores have to be quoted
+	if strings.ContainsAny(label, " .:=[]'") {
+		label = fmt.Sprintf("'%s'", strings.ReplaceAll(label, "'", "''"))
+	}
+
+	return fmt.Sprintf("%s=%d;%s;%s", label, daysLeft(certificate.NotAfter),
+		nagiosThreshold(report.WarnDays), nagiosThreshold(report.CritDays))
+}
+
+// Renders the plugin output: a status line with the days left until the leaf expires, the
+// messages of failed and warned validations and the days left of each certificate as
+// performance data
+func (report *Report) Nagios() (string, error) {
+	state, ok := nagiosStates[report.Status]
+	if !ok {
+		state = "UNKNOWN"
+	}
+
+	chains := []Address{{
+		Certificates: report.Certificates,
+	}}
+	if len(report.Addresses) > 0 {
+		chains = report.Addresses
+	}
+
+	perfData := []string{}
+	leafDaysLeft := []int{}
+	for _, chain := range chains {
+		for idx, certificate := range chain.Certificates {
+			label := "days_left"
+			if idx > 0 {
+				label = fmt.Sprintf("days_left_%d", idx)
+			}
+			if chain.Address != "" {
+				label = fmt.Sprintf("%s %s", chain.Address, label)
+			}
+
+			perfData = append(perfData, report.nagiosPerfData(label, certificate))
+		}
+
+		if len(chain.Certificates) > 0 {
+			leafDaysLeft = append(leafDaysLeft, daysLeft(chain.Certificates[0].NotAfter))
+		}
+	}
+
+	// The first message of the overall state is added to the status line
+	messages := []string{}
+	stateMessage := ""
+	for _, scope := range report.scopes() {
+		for _, scopeValidation := range scope.validations {
+			switch scopeValidation.Status {
+			case validation.StatusWarn, validation.StatusFail:
+				message := fmt.Sprintf("%s: %s", scope.name, nagiosText(scopeValidation.Message))
+				messages = append(messages, message)
+
+				if stateMessage == "" && scopeValidation.Status == report.Status {
+					stateMessage = message
+				}
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("%s has no certificates", report.Host)
+	if len(leafDaysLeft) > 0 {
+		minDaysLeft := leafDaysLeft[0]
+		for _, days := range leafDaysLeft[1:] {
+			if days < minDaysLeft {
+				minDaysLeft = days
+			}
+		}
+
+		summary = fmt.Sprintf("%s expires in %dd", report.Host, minDaysLeft)
+		if minDaysLeft < 0 {
+			summary = fmt.Sprintf("%s expired %dd ago", report.Host, -minDaysLeft)
+		}
+	}
+
+	if stateMessage != "" {
+		summary = fmt.Sprintf("%s, %s", summary, stateMessage)
+	}
+
+	var output strings.Builder
+	fmt.Fprintf(&output, "%s %s - %s | %s\n", nagiosServiceName, state, nagiosText(summary),
+		strings.Join(perfData, " "))
+	for _, message := range messages {
+		fmt.Fprintf(&output, "%s\n", message)
+	}
+
+	return output.String(), nil
+}
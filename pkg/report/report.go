@@ -0,0 +1,213 @@
+package report
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sgnn7/crtool/pkg/certificates/inspection"
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+)
+
+// Version of the machine-readable output schema. It is only increased on incompatible
+// changes (renamed or removed fields) and not when fields are added.
+const SchemaVersion = 1
+
+type Format string
+
+const (
+	FormatText   Format = "text"
+	FormatJSON   Format = "json"
+	FormatJUnit  Format = "junit"
+	FormatSARIF  Format = "sarif"
+	FormatNagios Format = "nagios"
+)
+
+func NewFormatFromStr(formatStr string) (Format, error) {
+	switch formatStr {
+	case "text", "TEXT":
+		return FormatText, nil
+	case "json", "JSON":
+		return FormatJSON, nil
+	case "junit", "JUNIT":
+		return FormatJUnit, nil
+	case "sarif", "SARIF":
+		return FormatSARIF, nil
+	case "nagios", "NAGIOS", "icinga", "ICINGA":
+		return FormatNagios, nil
+	}
+
+	return "", errors.New(fmt.Sprintf("output format '%s' is not supported!", formatStr))
+}
+
+type Validation struct {
+	ValidationType validation.ValidationType `json:"-"`
+
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type Certificate struct {
+	Index int `json:"index"`
+
+	inspection.Details
+
+	// Only set by verify
+	Validations []Validation `json:"validations,omitempty"`
+}
+
+// Chain served by one of the addresses of a target (only used when probing all addresses)
+type Address struct {
+	Address      string        `json:"address"`
+	Error        string        `json:"error,omitempty"`
+	Validations  []Validation  `json:"validations"`
+	Certificates []Certificate `json:"certificates"`
+}
+
+type Report struct {
+	SchemaVersion int    `json:"schema_version"`
+	Command       string `json:"command"`
+	Target        string `json:"target"`
+	Host          string `json:"host"`
+
+	// Overall result of verify ('pass', 'warn' or 'fail')
+	Status string `json:"status,omitempty"`
+
+	// Expiry thresholds of verify in days (omitted if disabled)
+	WarnDays int `json:"warn_days,omitempty"`
+	CritDays int `json:"crit_days,omitempty"`
+
+	// Validations of the whole chain (or of all addresses)
+	Validations  []Validation  `json:"validations"`
+	Certificates []Certificate `json:"certificates"`
+	Addresses    []Address     `json:"addresses,omitempty"`
+}
+
+func New(command string, target string, host string) *Report {
+	return &Report{
+		SchemaVersion: SchemaVersion,
+		Command:       command,
+		Target:        target,
+		Host:          host,
+		Validations:   []Validation{},
+		Certificates:  []Certificate{},
+	}
+}
+
+// Report of a chain that could not be retrieved with only the failed connection validation
+func NewConnectionFailure(command string, target string, err error) *Report {
+	failureReport := New(command, target, "")
+	failureReport.Status = validation.StatusFail
+	failureReport.Validations = []Validation{{
+		ValidationType: validation.ValidationTypeConnection,
+
+		Type:    validation.ValidationTypeConnection.String(),
+		Status:  validation.StatusFail,
+		Message: err.Error(),
+	}}
+
+	return failureReport
+}
+
+func NewValidations(results []validation.ValidationResult) []Validation {
+	validations := make([]Validation, len(results))
+	for idx, result := range results {
+		validations[idx] = Validation{
+			ValidationType: result.Type,
+
+			Type:    result.Type.String(),
+			Status:  result.Status,
+			Message: result.Message,
+		}
+	}
+
+	return validations
+}
+
+func NewCertificates(certs []*x509.Certificate) []Certificate {
+	certificates := make([]Certificate, len(certs))
+	for idx, cert := range certs {
+		certificates[idx] = Certificate{
+			Index:   idx,
+			Details: inspection.NewDetails(cert),
+		}
+	}
+
+	return certificates
+}
+
+// A set of validations that belong together (e.g. of the chain or of one certificate)
+type validationScope struct {
+	name        string
+	address     string
+	certificate *Certificate
+	validations []Validation
+}
+
+func chainScopes(prefix string, address string, validations []Validation,
+	certificates []Certificate) []validationScope {
+
+	scopes := []validationScope{{
+		name:        prefix + "chain",
+		address:     address,
+		validations: validations,
+	}}
+
+	for idx := range certificates {
+		certificate := &certificates[idx]
+		scopes = append(scopes, validationScope{
+			name: fmt.Sprintf("%scertificate %d/%d (%s)", prefix, idx+1, len(certificates),
+				certificate.Subject),
+			address:     address,
+			certificate: certificate,
+			validations: certificate.Validations,
+		})
+	}
+
+	return scopes
+}
+
+// Groups all validations of the report in the order they were run
+func (report *Report) scopes() []validationScope {
+	if len(report.Addresses) == 0 {
+		return chainScopes("", "", report.Validations, report.Certificates)
+	}
+
+	scopes := []validationScope{}
+	for _, address := range report.Addresses {
+		scopes = append(scopes, chainScopes(address.Address+": ", address.Address,
+			address.Validations, address.Certificates)...)
+	}
+
+	return append(scopes, validationScope{
+		name:        "addresses",
+		validations: report.Validations,
+	})
+}
+
+// Renders the report in a machine-readable format
+func (report *Report) Render(format Format) (string, error) {
+	switch format {
+	case FormatJSON:
+		return report.JSON()
+	case FormatJUnit:
+		return report.JUnit()
+	case FormatSARIF:
+		return report.SARIF()
+	case FormatNagios:
+		return report.Nagios()
+	}
+
+	return "", errors.New(fmt.Sprintf("output format '%s' is not supported!", format))
+}
+
+func (report *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,178 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+	"github.com/sgnn7/crtool/pkg/version"
+)
+
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+
+	sarifInformationURI = "https://github.com/sgnn7/crtool"
+	sarifRuleIDPrefix   = "crtool/"
+	fileSchemaStr       = "file://"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// Levels of the validation statuses that are reported as results
+var sarifLevels = map[string]string{
+	validation.StatusWarn: "warning",
+	validation.StatusFail: "error",
+}
+
+func sarifRuleID(scopeValidation Validation) string {
+	return sarifRuleIDPrefix + scopeValidation.Type
+}
+
+func (report *Report) sarifLocation(scope validationScope) sarifLocation {
+	name := report.Target
+	if scope.address != "" {
+		name = scope.address
+	}
+	if scope.certificate != nil {
+		name = scope.certificate.Subject
+	}
+
+	location := sarifLocation{
+		LogicalLocations: []sarifLogicalLocation{{
+			Name:               name,
+			FullyQualifiedName: fmt.Sprintf("%s/%s", report.Target, scope.name),
+			Kind:               "resource",
+		}},
+	}
+
+	// Only local targets can be attributed to a file
+	if strings.HasPrefix(report.Target, fileSchemaStr) {
+		location.PhysicalLocation = &sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{
+				URI: strings.TrimPrefix(report.Target, fileSchemaStr),
+			},
+		}
+	}
+
+	return location
+}
+
+// Renders each failed (or warned) validation as a result of a rule derived from its validation type
+func (report *Report) SARIF() (string, error) {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "crtool",
+				Version:        version.FullVersionName,
+				InformationURI: sarifInformationURI,
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+
+	ruleIndexes := map[string]int{}
+	for _, scope := range report.scopes() {
+		for _, scopeValidation := range scope.validations {
+			level, ok := sarifLevels[scopeValidation.Status]
+			if !ok {
+				continue
+			}
+
+			ruleID := sarifRuleID(scopeValidation)
+			ruleIndex, ok := ruleIndexes[ruleID]
+			if !ok {
+				ruleIndex = len(run.Tool.Driver.Rules)
+				ruleIndexes[ruleID] = ruleIndex
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:   ruleID,
+					Name: scopeValidation.Type,
+					ShortDescription: sarifMessage{
+						Text: scopeValidation.ValidationType.Description(),
+					},
+				})
+			}
+
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    ruleID,
+				RuleIndex: ruleIndex,
+				Level:     level,
+				Message: sarifMessage{
+					Text: fmt.Sprintf("%s: %s", scope.name, scopeValidation.Message),
+				},
+				Locations: []sarifLocation{report.sarifLocation(scope)},
+			})
+		}
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
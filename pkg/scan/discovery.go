@@ -0,0 +1,326 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sgnn7/crtool/pkg/certificates/inspection"
+	certProviders "github.com/sgnn7/crtool/pkg/certificates/providers"
+	"github.com/sgnn7/crtool/pkg/report"
+)
+
+// Largest number of addresses of all ranges of a discovery scan (a /16 IPv4 network)
+const maxDiscoveryAddresses = 1 << 16
+
+// Parses a comma-separated list of ports
+func ParsePorts(portList string) ([]string, error) {
+	ports := []string{}
+	for _, port := range strings.Split(portList, ",") {
+		if port = strings.TrimSpace(port); port == "" {
+			continue
+		}
+
+		portNumber, err := strconv.Atoi(port)
+		if err != nil || portNumber < 1 || portNumber > 65535 {
+			return nil, errors.New(fmt.Sprintf("port '%s' is not valid!", port))
+		}
+
+		ports = append(ports, port)
+	}
+
+	if len(ports) == 0 {
+		return nil, errors.New("no ports to scan")
+	}
+
+	return ports, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := append(net.IP{}, ip...)
+	for idx := len(next) - 1; idx >= 0; idx-- {
+		next[idx]++
+		if next[idx] != 0 {
+			break
+		}
+	}
+
+	return next
+}
+
+// Lists the host addresses of a range, failing if it has more than the limit. The network and
+// broadcast addresses of IPv4 ranges are skipped unless the range is a point-to-point (/31)
+// or single host (/32) one.
+func rangeAddresses(cidr string, limit int) ([]net.IP, error) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		// Single addresses are scanned as such
+		ip = net.ParseIP(cidr)
+		if ip == nil {
+			return nil, errors.New(fmt.Sprintf("'%s' is not a valid CIDR range or IP address!",
+				cidr))
+		}
+
+		if limit < 1 {
+			return nil, errors.New(fmt.Sprintf("address '%s' exceeds the maximum of %d "+
+				"addresses of all ranges", cidr, maxDiscoveryAddresses))
+		}
+
+		return []net.IP{ip}, nil
+	}
+
+	ones, bits := network.Mask.Size()
+	if bits-ones > 31 || 1<<uint(bits-ones) > limit {
+		return nil, errors.New(fmt.Sprintf("range '%s' has more than %d addresses (the "+
+			"maximum of all ranges is %d)", cidr, limit, maxDiscoveryAddresses))
+	}
+
+	addresses := []net.IP{}
+	for ip := ip.Mask(network.Mask); network.Contains(ip); ip = nextIP(ip) {
+		addresses = append(addresses, ip)
+	}
+
+	if ip.To4() != nil && bits-ones > 1 {
+		addresses = addresses[1 : len(addresses)-1]
+	}
+
+	return addresses, nil
+}
+
+// Lists the endpoints of each port of each address of the CIDR ranges (or single addresses)
+func ExpandCIDRs(cidrs []string, ports []string) ([]Target, error) {
+	addresses := []net.IP{}
+	for _, cidr := range cidrs {
+		rangeIPs, err := rangeAddresses(cidr, maxDiscoveryAddresses-len(addresses))
+		if err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, rangeIPs...)
+	}
+
+	targets := []Target{}
+	for _, address := range addresses {
+		for _, port := range ports {
+			targets = append(targets, Target{Target: address.String(), Port: port})
+		}
+	}
+
+	return targets, nil
+}
+
+// Certificate found on a discovered service
+type InventoryCertificate struct {
+	Index      int       `json:"index"`
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	DaysLeft   int       `json:"days_left"`
+	SelfSigned bool      `json:"self_signed"`
+
+	SHA256Fingerprint string `json:"sha256_fingerprint"`
+}
+
+// TLS service found by a discovery scan
+type Service struct {
+	Address      string                 `json:"-"`
+	SelfSigned   bool                   `json:"self_signed"`
+	Certificates []InventoryCertificate `json:"certificates"`
+}
+
+type InventorySummary struct {
+	Endpoints  int `json:"endpoints"`
+	Services   int `json:"services"`
+	SelfSigned int `json:"self_signed"`
+}
+
+// Inventory of the certificates of the TLS services of a discovery scan keyed by 'ip:port'
+type Inventory struct {
+	SchemaVersion int      `json:"schema_version"`
+	Command       string   `json:"command"`
+	Ranges        []string `json:"ranges"`
+	Ports         []string `json:"ports"`
+
+	Summary  InventorySummary   `json:"summary"`
+	Services map[string]Service `json:"services"`
+
+	// Addresses of the services in the order of the scanned ranges
+	addresses []string
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func newService(address string, certs []*x509.Certificate) Service {
+	service := Service{
+		Address:      address,
+		Certificates: []InventoryCertificate{},
+	}
+
+	for idx, cert := range certs {
+		fingerprint := sha256.Sum256(cert.Raw)
+		inventoryCert := InventoryCertificate{
+			Index:             idx,
+			Subject:           cert.Subject.String(),
+			Issuer:            cert.Issuer.String(),
+			NotBefore:         cert.NotBefore,
+			NotAfter:          cert.NotAfter,
+			DaysLeft:          int(time.Until(cert.NotAfter).Hours() / 24),
+			SelfSigned:        isSelfSigned(cert),
+			SHA256Fingerprint: inspection.HexString(fingerprint[:]),
+		}
+
+		service.Certificates = append(service.Certificates, inventoryCert)
+	}
+
+	// Services that only present a self-signed leaf are the ones that were set up ad hoc
+	service.SelfSigned = len(certs) == 1 && service.Certificates[0].SelfSigned
+
+	return service
+}
+
+// Attempts TLS handshakes with every port of every address of the ranges and records the
+// certificates of each service that completed the handshake. Endpoints that are closed or
+// do not speak TLS are only logged in debug mode.
+func DiscoverServices(
+	ctx context.Context,
+	cidrs []string,
+	ports []string,
+	options Options,
+) (*Inventory, error) {
+
+	if err := checkFormat(options.Format); err != nil {
+		return nil, err
+	}
+
+	for idx := range cidrs {
+		cidrs[idx] = strings.TrimSpace(cidrs[idx])
+	}
+
+	targets, err := ExpandCIDRs(cidrs, ports)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no addresses to scan")
+	}
+
+	services := make([]*Service, len(targets))
+	runWorkers(ctx, len(targets), options.Concurrency, options.Rate, func(idx int) {
+		targetCtx := ctx
+		if options.TargetTimeout > 0 {
+			var cancel context.CancelFunc
+			targetCtx, cancel = context.WithTimeout(ctx, options.TargetTimeout)
+			defer cancel()
+		}
+
+		// Discovery only lists the certificates so client certificate requests are not logged
+		providerOptions := options.Options.Options
+		providerOptions.Quiet = true
+
+		target := targets[idx]
+		certs, _, err := certProviders.GetTLSCertificates(targetCtx, target.Target, target.Port,
+			providerOptions)
+		if err == nil && len(certs) == 0 {
+			err = errors.New("no certificates were presented")
+		}
+		if err != nil {
+			if options.Debug {
+				log.Printf("No TLS service found on '%s': %s", target, err)
+			}
+			return
+		}
+
+		service := newService(target.String(), certs)
+		services[idx] = &service
+	})
+
+	inventory := &Inventory{
+		SchemaVersion: report.SchemaVersion,
+		Command:       "scan",
+		Ranges:        cidrs,
+		Ports:         ports,
+		Summary: InventorySummary{
+			Endpoints: len(targets),
+		},
+		Services:  map[string]Service{},
+		addresses: []string{},
+	}
+
+	for _, service := range services {
+		if service == nil {
+			continue
+		}
+
+		inventory.Services[service.Address] = *service
+		inventory.addresses = append(inventory.addresses, service.Address)
+		inventory.Summary.Services++
+		if service.SelfSigned {
+			inventory.Summary.SelfSigned++
+		}
+	}
+
+	return inventory, nil
+}
+
+// Renders the inventory as a table of the certificates of each service followed by a summary
+func (inventory *Inventory) Text() (string, error) {
+	var output strings.Builder
+
+	table := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ADDRESS\tINDEX\tSUBJECT\tISSUER\tNOT AFTER\tDAYS LEFT\tSELF-SIGNED")
+	for _, address := range inventory.addresses {
+		for _, cert := range inventory.Services[address].Certificates {
+			selfSigned := "no"
+			if cert.SelfSigned {
+				selfSigned = "yes"
+			}
+
+			fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%d\t%s\n", address, cert.Index, cert.Subject,
+				cert.Issuer, cert.NotAfter.Format(time.RFC3339), cert.DaysLeft, selfSigned)
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return "", err
+	}
+
+	summary := inventory.Summary
+	fmt.Fprintf(&output,
+		"\nProbed %d endpoint(s): %d TLS service(s) found, %d with a self-signed certificate\n",
+		summary.Endpoints, summary.Services, summary.SelfSigned)
+
+	return output.String(), nil
+}
+
+func (inventory *Inventory) JSON() (string, error) {
+	data, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
+
+func (inventory *Inventory) Render(format report.Format) (string, error) {
+	if err := checkFormat(format); err != nil {
+		return "", err
+	}
+
+	if format == report.FormatJSON {
+		return inventory.JSON()
+	}
+
+	return inventory.Text()
+}
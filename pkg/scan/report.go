@@ -0,0 +1,167 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+	"github.com/sgnn7/crtool/pkg/report"
+	"github.com/sgnn7/crtool/pkg/ssl"
+)
+
+type Summary struct {
+	Total int `json:"total"`
+	Pass  int `json:"pass"`
+	Warn  int `json:"warn"`
+	Fail  int `json:"fail"`
+
+	// Number of targets with each type of failed validation
+	Failures map[string]int `json:"failures"`
+}
+
+// Consolidated report of a scan with the results sorted by the soonest expiry
+type Report struct {
+	SchemaVersion int    `json:"schema_version"`
+	Command       string `json:"command"`
+	Status        string `json:"status"`
+
+	WarnDays int `json:"warn_days,omitempty"`
+	CritDays int `json:"crit_days,omitempty"`
+
+	Summary Summary  `json:"summary"`
+	Results []Result `json:"results"`
+}
+
+func newReport(results []Result, options Options) *Report {
+	scanReport := &Report{
+		SchemaVersion: report.SchemaVersion,
+		Command:       "scan",
+		Status:        validation.StatusPass,
+		WarnDays:      options.WarnDays,
+		CritDays:      options.CritDays,
+		Summary: Summary{
+			Total:    len(results),
+			Failures: map[string]int{},
+		},
+		Results: results,
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case validation.StatusFail:
+			scanReport.Summary.Fail++
+			scanReport.Status = validation.StatusFail
+		case validation.StatusWarn:
+			scanReport.Summary.Warn++
+			if scanReport.Status == validation.StatusPass {
+				scanReport.Status = validation.StatusWarn
+			}
+		default:
+			scanReport.Summary.Pass++
+		}
+
+		for _, failure := range result.Failures {
+			scanReport.Summary.Failures[failure]++
+		}
+	}
+
+	return scanReport
+}
+
+// Renders the report as a table of the targets followed by a summary
+func (scanReport *Report) Text() (string, error) {
+	var output strings.Builder
+
+	table := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TARGET\tSTATUS\tNOT AFTER\tDAYS LEFT\tFAILURES\tWARNINGS")
+	for _, result := range scanReport.Results {
+		notAfter, daysLeft := "-", "-"
+		if result.NotAfter != nil {
+			notAfter = result.NotAfter.Format(time.RFC3339)
+			daysLeft = fmt.Sprint(*result.DaysLeft)
+		}
+
+		failures := strings.Join(result.Failures, ",")
+		if result.Error != "" {
+			failures = fmt.Sprintf("%s (%s)", failures, result.Error)
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Target, result.Status, notAfter,
+			daysLeft, failures, strings.Join(result.Warnings, ","))
+	}
+	if err := table.Flush(); err != nil {
+		return "", err
+	}
+
+	summary := scanReport.Summary
+	fmt.Fprintf(&output, "\nScanned %d target(s): %d passed, %d warned, %d failed\n",
+		summary.Total, summary.Pass, summary.Warn, summary.Fail)
+
+	failureTypes := []string{}
+	for failureType := range summary.Failures {
+		failureTypes = append(failureTypes, failureType)
+	}
+	sort.Slice(failureTypes, func(i, j int) bool {
+		left, right := failureTypes[i], failureTypes[j]
+		if summary.Failures[left] != summary.Failures[right] {
+			return summary.Failures[left] > summary.Failures[right]
+		}
+
+		return left < right
+	})
+
+	for _, failureType := range failureTypes {
+		fmt.Fprintf(&output, "  %-18s %d\n", failureType+":", summary.Failures[failureType])
+	}
+
+	return output.String(), nil
+}
+
+// Error of the overall status that maps to the same exit codes as verify
+func (scanReport *Report) Err() error {
+	switch scanReport.Status {
+	case validation.StatusFail:
+		return fmt.Errorf("%d of %d target(s) failed: %w", scanReport.Summary.Fail,
+			scanReport.Summary.Total, ssl.ErrValidationFailed)
+	case validation.StatusWarn:
+		return fmt.Errorf("%d of %d target(s) warned: %w", scanReport.Summary.Warn,
+			scanReport.Summary.Total, ssl.ErrValidationWarning)
+	}
+
+	return nil
+}
+
+func (scanReport *Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(scanReport, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
+
+func checkFormat(format report.Format) error {
+	switch format {
+	case "", report.FormatText, report.FormatJSON:
+		return nil
+	}
+
+	return errors.New(fmt.Sprintf("output format '%s' is not supported by scan", format))
+}
+
+func (scanReport *Report) Render(format report.Format) (string, error) {
+	if err := checkFormat(format); err != nil {
+		return "", err
+	}
+
+	if format == report.FormatJSON {
+		return scanReport.JSON()
+	}
+
+	return scanReport.Text()
+}
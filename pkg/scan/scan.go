@@ -0,0 +1,267 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+	"github.com/sgnn7/crtool/pkg/report"
+	"github.com/sgnn7/crtool/pkg/ssl"
+)
+
+const (
+	DefaultConcurrency   = 10
+	DefaultTargetTimeout = time.Minute
+)
+
+type Options struct {
+	ssl.Options
+
+	// Number of targets scanned at the same time
+	Concurrency int
+
+	// Limit of the whole retrieval and validation of each target (0 disables it)
+	TargetTimeout time.Duration
+
+	// Maximum number of targets started per second (0 disables rate limiting)
+	Rate float64
+
+	// Port of targets that do not specify one
+	Port string
+}
+
+// Outcome of scanning a single target
+type Result struct {
+	Target string `json:"target"`
+	Host   string `json:"host,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// Soonest expiry of the certificates of the chain
+	NotAfter *time.Time `json:"not_after,omitempty"`
+	DaysLeft *int       `json:"days_left,omitempty"`
+
+	// Types of the failed and warned validations
+	Failures []string `json:"failures,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Messages of the failed and warned validations
+	Messages []string `json:"messages,omitempty"`
+
+	Duration float64 `json:"duration_seconds"`
+}
+
+func addUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
+
+func newResult(target Target, scanReport *report.Report, err error, duration time.Duration) Result {
+	result := Result{
+		Target:   target.String(),
+		Duration: duration.Seconds(),
+	}
+
+	if err != nil {
+		result.Status = validation.StatusFail
+		result.Error = err.Error()
+		result.Failures = []string{validation.ValidationTypeConnection.String()}
+		return result
+	}
+
+	result.Host = scanReport.Host
+	result.Status = scanReport.Status
+
+	validations := append([]report.Validation{}, scanReport.Validations...)
+	for _, certificate := range scanReport.Certificates {
+		validations = append(validations, certificate.Validations...)
+
+		if result.NotAfter == nil || certificate.NotAfter.Before(*result.NotAfter) {
+			notAfter := certificate.NotAfter
+			result.NotAfter = &notAfter
+		}
+	}
+
+	if result.NotAfter != nil {
+		daysLeft := int(time.Until(*result.NotAfter).Hours() / 24)
+		result.DaysLeft = &daysLeft
+	}
+
+	for _, targetValidation := range validations {
+		switch targetValidation.Status {
+		case validation.StatusFail:
+			result.Failures = addUnique(result.Failures, targetValidation.Type)
+		case validation.StatusWarn:
+			result.Warnings = addUnique(result.Warnings, targetValidation.Type)
+		default:
+			continue
+		}
+
+		if targetValidation.Message != "" {
+			result.Messages = append(result.Messages, targetValidation.Message)
+		}
+	}
+
+	return result
+}
+
+func scanTarget(ctx context.Context, target Target, options Options) Result {
+	if options.TargetTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.TargetTimeout)
+		defer cancel()
+	}
+
+	port := target.Port
+	if port == "" {
+		port = options.Port
+	}
+
+	start := time.Now()
+	scanReport, err := ssl.ValidateServerCertChain(ctx, target.Target, port, options.Options)
+	duration := time.Since(start)
+
+	result := newResult(target, scanReport, err, duration)
+
+	if options.Debug {
+		log.Printf("Scan of '%s' finished with status '%s' in %s", result.Target, result.Status,
+			duration)
+	}
+
+	return result
+}
+
+// Runs the scan function over the indices of the targets with a pool of workers. Workers
+// are started at most at the given rate. Targets that were not started before the context
+// was done are not scanned.
+func runWorkers(
+	ctx context.Context,
+	count int,
+	concurrency int,
+	rate float64,
+	scanFunc func(idx int),
+) {
+
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	indices := make(chan int)
+
+	var waitGroup sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < count; worker++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for idx := range indices {
+				scanFunc(idx)
+			}
+		}()
+	}
+
+	var ticker *time.Ticker
+	if rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+	}
+
+dispatch:
+	for idx := 0; idx < count; idx++ {
+		if ticker != nil && idx > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+
+		select {
+		case indices <- idx:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(indices)
+	waitGroup.Wait()
+}
+
+// Severity of the status used for sorting results
+var statusOrder = map[string]int{
+	validation.StatusFail: 0,
+	validation.StatusWarn: 1,
+	validation.StatusPass: 2,
+}
+
+func firstFailure(result Result) string {
+	if len(result.Failures) == 0 {
+		return ""
+	}
+
+	return result.Failures[0]
+}
+
+// Sorts results by the soonest expiry and then by failure type. Targets whose certificates
+// could not be retrieved have no expiry and are grouped by failure at the end.
+func sortResults(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		left, right := results[i], results[j]
+
+		if (left.NotAfter == nil) != (right.NotAfter == nil) {
+			return left.NotAfter != nil
+		}
+		if left.NotAfter != nil && !left.NotAfter.Equal(*right.NotAfter) {
+			return left.NotAfter.Before(*right.NotAfter)
+		}
+
+		if statusOrder[left.Status] != statusOrder[right.Status] {
+			return statusOrder[left.Status] < statusOrder[right.Status]
+		}
+		if firstFailure(left) != firstFailure(right) {
+			return firstFailure(left) < firstFailure(right)
+		}
+
+		return left.Target < right.Target
+	})
+}
+
+// Retrieves and validates the chains of all targets and returns the consolidated report
+func ScanTargets(ctx context.Context, targets []Target, options Options) (*Report, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no targets to scan")
+	}
+
+	// Fail before scanning all targets instead of when rendering the report
+	if err := checkFormat(options.Format); err != nil {
+		return nil, err
+	}
+
+	// Targets share the client of CRL and OCSP requests and its connections
+	options.ValidationClient = ssl.NewValidationHTTPClient(options.Options)
+
+	results := make([]Result, len(targets))
+	scanned := make([]bool, len(targets))
+	runWorkers(ctx, len(targets), options.Concurrency, options.Rate, func(idx int) {
+		results[idx] = scanTarget(ctx, targets[idx], options)
+		scanned[idx] = true
+	})
+
+	for idx, target := range targets {
+		if !scanned[idx] {
+			results[idx] = newResult(target, nil, errors.New("scan was interrupted"), 0)
+		}
+	}
+
+	sortResults(results)
+
+	return newReport(results, options), nil
+}
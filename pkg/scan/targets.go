@@ -0,0 +1,97 @@
+package scan
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	certProviders "github.com/sgnn7/crtool/pkg/certificates/providers"
+)
+
+// Target of a scan with its port if the target list specified one
+type Target struct {
+	Target string
+	Port   string
+}
+
+// Name of the target in the scan report ('host:port' if the port is known). The port of URL
+// targets is added to their host unless the URL has one which takes precedence.
+func (target Target) String() string {
+	if target.Port == "" {
+		return target.Target
+	}
+
+	if strings.Contains(target.Target, "://") {
+		targetURL, err := url.Parse(target.Target)
+		if err != nil || targetURL.Host == "" || targetURL.Port() != "" {
+			return target.Target
+		}
+
+		targetURL.Host = net.JoinHostPort(targetURL.Hostname(), target.Port)
+		return targetURL.String()
+	}
+
+	return net.JoinHostPort(target.Target, target.Port)
+}
+
+// Splits a 'host:port' target whose port takes precedence over the given one
+func NewTarget(target string, port string) Target {
+	host, hostPort := certProviders.SplitTargetPort(target, port)
+
+	return Target{Target: host, Port: hostPort}
+}
+
+// Reads targets from a list with a 'host[:port]', URL or 'file://' target per line. CSV lists
+// are accepted too with the target in the first column and optionally the port in the second
+// one. Empty lines, lines starting with '#' and a 'target' header row are skipped.
+func ReadTargets(reader io.Reader) ([]Target, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	targets := []Target{}
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("could not read targets: %s", err))
+		}
+
+		target := strings.TrimSpace(record[0])
+		if target == "" || (len(targets) == 0 && strings.EqualFold(target, "target")) {
+			continue
+		}
+
+		port := ""
+		if len(record) > 1 {
+			port = strings.TrimSpace(record[1])
+		}
+
+		targets = append(targets, NewTarget(target, port))
+	}
+
+	return targets, nil
+}
+
+// Reads the targets of a list file ('-' for stdin)
+func LoadTargets(path string) ([]Target, error) {
+	if certProviders.IsStdinTarget(path) {
+		return ReadTargets(os.Stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadTargets(file)
+}
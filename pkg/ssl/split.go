@@ -0,0 +1,173 @@
+package ssl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/template"
+
+	"github.com/sgnn7/crtool/pkg/encoding"
+)
+
+const (
+	DefaultNameTemplate = "cert-{{.Index}}{{.Ext}}"
+
+	RoleLeaf         = "leaf"
+	RoleIntermediate = "intermediate"
+	RoleRoot         = "root"
+	RoleOther        = "other"
+)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Fields available in the name template of split certificate files
+type SplitFileName struct {
+	// Position of the certificate in the chain (0 is the leaf)
+	Index int
+
+	// Common name of the subject with characters that are unsafe in file names replaced
+	CN string
+
+	// One of 'leaf', 'intermediate', 'root' or 'other'
+	Role string
+
+	// Hex-encoded SHA-256 fingerprint of the certificate
+	SHA256 string
+
+	// File extension of the output encoding (e.g. '.pem')
+	Ext string
+}
+
+// Derives the role of a certificate from its position in the chain and its CA flag. CA
+// certificates after the leaf are roots if they are self-issued and intermediates otherwise
+// while non-CA certificates after the leaf (e.g. of misconfigured servers) are 'other'.
+func certificateRole(idx int, cert *x509.Certificate) string {
+	if idx == 0 {
+		return RoleLeaf
+	}
+
+	if !cert.IsCA {
+		return RoleOther
+	}
+
+	if bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+		return RoleRoot
+	}
+
+	return RoleIntermediate
+}
+
+func newSplitFileName(idx int, cert *x509.Certificate, encType encoding.EncodingType) SplitFileName {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	return SplitFileName{
+		Index:  idx,
+		CN:     unsafeFileNameChars.ReplaceAllString(cert.Subject.CommonName, "_"),
+		Role:   certificateRole(idx, cert),
+		SHA256: hex.EncodeToString(fingerprint[:]),
+		Ext:    encType.FileExtension(),
+	}
+}
+
+// Renders the name of a certificate's file which has to be relative to the output directory
+func splitFileName(
+	nameTemplate *template.Template,
+	idx int,
+	cert *x509.Certificate,
+	encType encoding.EncodingType,
+) (string, error) {
+
+	var filename bytes.Buffer
+	err := nameTemplate.Execute(&filename, newSplitFileName(idx, cert, encType))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("invalid name template: %s", err.Error()))
+	}
+
+	name := filename.String()
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", errors.New(fmt.Sprintf("name template produced invalid file name '%s' "+
+			"for '%s'", name, cert.Subject))
+	}
+
+	return name, nil
+}
+
+func newSplitNameTemplate(options Options) (*template.Template, error) {
+	nameTemplate := options.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = DefaultNameTemplate
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid name template: %s", err.Error()))
+	}
+
+	return tmpl, nil
+}
+
+// Writes every certificate to its own file in the output directory, named with the name
+// template
+func writeSplitCerts(
+	certs []*x509.Certificate,
+	encType encoding.EncodingType,
+	nameTemplate *template.Template,
+	options Options,
+) error {
+
+	outputDir := options.OutputFile
+	if outputDir == "" {
+		outputDir = "."
+	}
+
+	// The original position in the chain is kept in the name if a single cert is selected
+	firstIdx := 0
+	if options.Index >= 0 {
+		firstIdx = options.Index
+	}
+
+	// All names are checked before any file is written
+	names := make([]string, len(certs))
+	usedNames := map[string]bool{}
+	for idx, cert := range certs {
+		name, err := splitFileName(nameTemplate, firstIdx+idx, cert, encType)
+		if err != nil {
+			return err
+		}
+
+		if usedNames[name] {
+			return errors.New(fmt.Sprintf("name template produced '%s' for more than one "+
+				"certificate", name))
+		}
+		usedNames[name] = true
+		names[idx] = name
+	}
+
+	if err := os.MkdirAll(outputDir, 0750); err != nil {
+		return err
+	}
+
+	for idx, cert := range certs {
+		encData, err := encodeCerts([][]byte{cert.Raw}, encType, options)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(outputDir, names[idx])
+		if err := ioutil.WriteFile(path, encData, 0640); err != nil {
+			return err
+		}
+
+		log.Printf("Wrote '%s' to %s", cert.Subject, path)
+	}
+
+	return nil
+}
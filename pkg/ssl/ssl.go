@@ -1,39 +1,154 @@
 package ssl
 
 import (
+	"context"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/sgnn7/crtool/pkg/certificates/inspection"
 	certProviders "github.com/sgnn7/crtool/pkg/certificates/providers"
 	"github.com/sgnn7/crtool/pkg/certificates/validation"
 	"github.com/sgnn7/crtool/pkg/encoding"
+	"github.com/sgnn7/crtool/pkg/report"
 )
 
 type Options struct {
-	Debug      bool
+	certProviders.Options
+
+	// Probe every resolved IP address of the target instead of a single one
+	AllIPs bool
+
 	OutputFile string
+
+	// Index of the only certificate of the chain to output (negative values select all)
+	Index int
+
+	// Write each certificate of the chain to its own file in the output directory
+	Split bool
+
+	// Template of the file names used when splitting the chain (see SplitFileName)
+	NameTemplate string
+
+	// Expiry thresholds (in days) of verify for warnings and failures (0 disables them)
+	WarnDays int
+	CritDays int
+
+	// Output format of show and verify (defaults to text, show only supports text and JSON)
+	Format report.Format
+
+	// Client of CRL and OCSP requests shared by concurrent validations (created from the
+	// timeout and proxy options if not set)
+	ValidationClient *http.Client
+}
+
+func rawCertificates(certs []*x509.Certificate) [][]byte {
+	rawCerts := make([][]byte, len(certs))
+	for idx, cert := range certs {
+		rawCerts[idx] = cert.Raw
+	}
+
+	return rawCerts
+}
+
+// Narrows the chain down to the certificate selected with the index option
+func selectCertificates(certs []*x509.Certificate, options Options) ([]*x509.Certificate, error) {
+	if options.Index < 0 {
+		return certs, nil
+	}
+
+	if options.Index >= len(certs) {
+		return nil, errors.New(fmt.Sprintf("certificate index %d is out of range (chain has %d "+
+			"certificate(s))", options.Index, len(certs)))
+	}
+
+	return certs[options.Index : options.Index+1], nil
+}
+
+func encodeCerts(rawCerts [][]byte, encType encoding.EncodingType, options Options) ([]byte, error) {
+	// Keystore outputs are protected with the same password option as keystore inputs
+	if encType == encoding.JKS {
+		password, err := options.KeystorePassword()
+		if err != nil {
+			return nil, err
+		}
+
+		return encoding.EncodeJKS(rawCerts, password)
+	}
+
+	return encoding.EncodeCerts(rawCerts, encType)
+}
+
+func chainFingerprint(certs []*x509.Certificate) string {
+	hash := sha256.New()
+	for _, cert := range certs {
+		hash.Write(cert.Raw)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Groups addresses by the fingerprint of the chain they serve
+func groupAddressesByChain(results []certProviders.AddressCertificates) map[string][]string {
+	chains := map[string][]string{}
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		fingerprint := chainFingerprint(result.Certificates)
+		chains[fingerprint] = append(chains[fingerprint], result.Address)
+	}
+
+	return chains
 }
 
 func GetServerCert(
+	ctx context.Context,
 	target string,
 	port string,
 	encType encoding.EncodingType,
 	options Options,
 ) (string, error) {
 
-	certs, _, err := certProviders.GetCertificates(target, port, options.Debug)
+	if options.AllIPs {
+		return getAllAddressesServerCerts(ctx, target, port, encType, options)
+	}
+
+	// Bad templates are reported before connecting to the target
+	nameTemplate, err := newSplitNameTemplate(options)
 	if err != nil {
 		return "", err
 	}
 
-	rawCerts := make([][]byte, len(certs))
-	for idx, cert := range certs {
-		rawCerts[idx] = cert.Raw
+	certs, _, err := certProviders.GetCertificates(ctx, target, port, options.Options)
+	if err != nil {
+		return "", err
 	}
 
-	encData, err := encoding.EncodeCerts(rawCerts, encType)
+	certs, err = selectCertificates(certs, options)
+	if err != nil {
+		return "", err
+	}
+
+	if options.Split {
+		return "", writeSplitCerts(certs, encType, nameTemplate, options)
+	}
+
+	if encType == encoding.DER && len(certs) > 1 {
+		log.Printf("WARNING: DER encoding holds a single certificate - only the leaf is "+
+			"output and %d other certificate(s) of the chain are dropped (use --split or "+
+			"--index to output them)", len(certs)-1)
+	}
+
+	encData, err := encodeCerts(rawCertificates(certs), encType, options)
 	if err != nil {
 		return "", err
 	}
@@ -45,98 +160,552 @@ func GetServerCert(
 	return string(encData), nil
 }
 
-func VerifyServerCertChain(target string, port string, options Options) (string, error) {
-	certs, host, err := certProviders.GetCertificates(target, port, options.Debug)
+func getAllAddressesServerCerts(
+	ctx context.Context,
+	target string,
+	port string,
+	encType encoding.EncodingType,
+	options Options,
+) (string, error) {
+
+	if encType != encoding.PEM {
+		return "", errors.New("probing all addresses is only supported with PEM encoding")
+	}
+
+	if options.Split {
+		return "", errors.New("probing all addresses does not support splitting the chain")
+	}
+
+	results, _, err := certProviders.GetTLSCertificatesForAllAddresses(ctx, target, port,
+		options.Options)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("FAIL: %s: %s", result.Address, result.Err)
+			continue
+		}
+
+		// Addresses may serve chains of different lengths so only their dump is skipped
+		certs, err := selectCertificates(result.Certificates, options)
+		if err != nil {
+			log.Printf("WARNING: skipping %s: %s", result.Address, err)
+			continue
+		}
+
+		encData, err := encoding.EncodeCerts(rawCertificates(certs), encType)
+		if err != nil {
+			return "", err
+		}
+
+		// Text outside of PEM blocks is ignored by decoders so we can label each chain
+		fmt.Fprintf(&output, "# Address: %s\n", result.Address)
+		output.Write(encData)
+	}
+
+	if chains := groupAddressesByChain(results); len(chains) > 1 {
+		log.Printf("WARNING: addresses of '%s' serve %d different certificate chains", target,
+			len(chains))
+	}
+
+	if options.Debug {
+		log.Printf("Certificates retrieved")
+	}
+
+	return output.String(), nil
+}
+
+func describeCertificates(output *strings.Builder, certs []*x509.Certificate) {
+	for idx, cert := range certs {
+		if idx > 0 {
+			output.WriteString("\n")
+		}
+
+		fmt.Fprintf(output, "Certificate: %d/%d\n", idx+1, len(certs))
+		output.WriteString(inspection.DescribeCertificate(cert))
+	}
+}
+
+func ShowServerCerts(
+	ctx context.Context,
+	target string,
+	port string,
+	options Options,
+) (string, error) {
+
+	switch options.Format {
+	case "", report.FormatText, report.FormatJSON:
+	default:
+		return "", errors.New(fmt.Sprintf("output format '%s' is not supported by show",
+			options.Format))
+	}
+
+	if options.AllIPs {
+		return showAllAddressesServerCerts(ctx, target, port, options)
+	}
+
+	certs, host, err := certProviders.GetCertificates(ctx, target, port, options.Options)
+	if err != nil {
+		return "", err
+	}
+
+	if options.Format == report.FormatJSON {
+		certReport := report.New("show", target, host)
+		certReport.Certificates = report.NewCertificates(certs)
+		return certReport.JSON()
+	}
+
+	var output strings.Builder
+	describeCertificates(&output, certs)
+	return output.String(), nil
+}
+
+func showAllAddressesServerCerts(
+	ctx context.Context,
+	target string,
+	port string,
+	options Options,
+) (string, error) {
+
+	results, host, err := certProviders.GetTLSCertificatesForAllAddresses(ctx, target, port,
+		options.Options)
+	if err != nil {
+		return "", err
+	}
+
+	if options.Format == report.FormatJSON {
+		certReport := report.New("show", target, host)
+		for _, result := range results {
+			address := report.Address{
+				Address:      result.Address,
+				Validations:  []report.Validation{},
+				Certificates: report.NewCertificates(result.Certificates),
+			}
+			if result.Err != nil {
+				address.Error = result.Err.Error()
+			}
+			certReport.Addresses = append(certReport.Addresses, address)
+		}
+
+		return certReport.JSON()
+	}
+
+	var output strings.Builder
+	for idx, result := range results {
+		if idx > 0 {
+			output.WriteString("\n")
+		}
+
+		fmt.Fprintf(&output, "Address: %s (%d/%d)\n\n", result.Address, idx+1, len(results))
+		if result.Err != nil {
+			fmt.Fprintf(&output, "FAIL: %s\n", result.Err)
+			continue
+		}
+
+		describeCertificates(&output, result.Certificates)
+	}
+
+	return output.String(), nil
+}
+
+// Returned by verification if the chain was retrieved but failed validation
+var ErrValidationFailed = errors.New("fetched certificate chain failed validation")
+
+// Returned by verification if the chain passed validation but needs attention soon
+var ErrValidationWarning = errors.New("fetched certificate chain passed validation with warnings")
+
+// Runs validations and logs their results unless a machine-readable format is output
+type chainVerifier struct {
+	quiet bool
+
+	warnDays int
+	critDays int
+
+	// Client of CRL and OCSP requests
+	httpClient *http.Client
+}
+
+func newChainVerifier(options Options) *chainVerifier {
+	httpClient := options.ValidationClient
+	if httpClient == nil {
+		httpClient = NewValidationHTTPClient(options)
+	}
+
+	return &chainVerifier{
+		quiet:      options.Format != "" && options.Format != report.FormatText,
+		warnDays:   options.WarnDays,
+		critDays:   options.CritDays,
+		httpClient: httpClient,
+	}
+}
+
+func (verifier *chainVerifier) logf(format string, args ...interface{}) {
+	if !verifier.quiet {
+		log.Printf(format, args...)
+	}
+}
+
+func (verifier *chainVerifier) logln() {
+	if !verifier.quiet {
+		log.Println()
+	}
+}
+
+// Tags the result with its type, logs it with the checked value and appends it
+func (verifier *chainVerifier) add(
+	validations []validation.ValidationResult,
+	validationType validation.ValidationType,
+	result validation.ValidationResult,
+	label string,
+	value interface{},
+) []validation.ValidationResult {
+
+	result.Type = validationType
+	verifier.logf("%s %-23s %v", result, label, value)
+
+	return append(validations, result)
+}
+
+// Results of validating the chain as a whole and each of its certificates
+type chainValidations struct {
+	chain        []validation.ValidationResult
+	certificates [][]validation.ValidationResult
+}
+
+func (validations chainValidations) all() []validation.ValidationResult {
+	results := append([]validation.ValidationResult{}, validations.chain...)
+	for _, certValidations := range validations.certificates {
+		results = append(results, certValidations...)
+	}
+
+	return results
+}
+
+func newChainReport(
+	certs []*x509.Certificate,
+	validations chainValidations,
+) ([]report.Validation, []report.Certificate) {
+
+	certificates := report.NewCertificates(certs)
+	for idx := range certificates {
+		if idx < len(validations.certificates) {
+			certificates[idx].Validations = report.NewValidations(validations.certificates[idx])
+		}
+	}
+
+	return report.NewValidations(validations.chain), certificates
+}
+
+// Creates the client of CRL and OCSP requests with the timeout and proxy options applied
+func NewValidationHTTPClient(options Options) *http.Client {
+	return &http.Client{
+		Timeout: options.Timeout,
+		Transport: &http.Transport{
+			Proxy: certProviders.HTTPProxyFunc(options.Options),
+		},
+	}
+}
+
+// Retrieves and validates the chain of a target like VerifyServerCertChain but without any
+// logging, returning the results as a report
+func ValidateServerCertChain(
+	ctx context.Context,
+	target string,
+	port string,
+	options Options,
+) (*report.Report, error) {
+
+	options.Quiet = true
+	certs, host, err := certProviders.GetCertificates(ctx, target, port, options.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier := newChainVerifier(options)
+	verifier.quiet = true
+	validations := verifier.verifyCertChain(ctx, certs, host)
+
+	validateReport := report.New("verify", target, host)
+	validateReport.Validations, validateReport.Certificates = newChainReport(certs, validations)
+	validateReport.WarnDays = options.WarnDays
+	validateReport.CritDays = options.CritDays
+	validateReport.Status = validationStatus(validations.all())
+
+	return validateReport, nil
+}
+
+func VerifyServerCertChain(
+	ctx context.Context,
+	target string,
+	port string,
+	options Options,
+) (string, error) {
+
+	if options.AllIPs {
+		return verifyAllAddressesCertChains(ctx, target, port, options)
+	}
+
+	certs, host, err := certProviders.GetCertificates(ctx, target, port, options.Options)
 	if err != nil {
 		return "", err
 	}
 
+	verifier := newChainVerifier(options)
+	validations := verifier.verifyCertChain(ctx, certs, host)
+
+	verifyReport := report.New("verify", target, host)
+	verifyReport.Validations, verifyReport.Certificates = newChainReport(certs, validations)
+
+	return verifier.reportValidations(verifyReport, validations.all(), options)
+}
+
+func verifyAllAddressesCertChains(
+	ctx context.Context,
+	target string,
+	port string,
+	options Options,
+) (string, error) {
+
+	results, host, err := certProviders.GetTLSCertificatesForAllAddresses(ctx, target, port,
+		options.Options)
+	if err != nil {
+		return "", err
+	}
+
+	verifier := newChainVerifier(options)
+	verifyReport := report.New("verify", target, host)
+
 	validations := []validation.ValidationResult{}
+	for idx, result := range results {
+		verifier.logf("Address: %s (%d/%d)", result.Address, idx+1, len(results))
+		verifier.logln()
+
+		address := report.Address{
+			Address:      result.Address,
+			Validations:  []report.Validation{},
+			Certificates: []report.Certificate{},
+		}
+
+		if result.Err != nil {
+			connectionValidation := validation.ValidationResultFail
+			connectionValidation.Message = fmt.Sprintf("%s: %s", result.Address, result.Err)
+
+			addressValidations := verifier.add(nil, validation.ValidationTypeConnection,
+				connectionValidation, "Connection:", result.Err)
+			verifier.logln()
+
+			validations = append(validations, addressValidations...)
+			address.Error = result.Err.Error()
+			address.Validations = report.NewValidations(addressValidations)
+			verifyReport.Addresses = append(verifyReport.Addresses, address)
+			continue
+		}
+
+		addressValidations := verifier.verifyCertChain(ctx, result.Certificates, host)
+		address.Validations, address.Certificates = newChainReport(result.Certificates,
+			addressValidations)
+		verifyReport.Addresses = append(verifyReport.Addresses, address)
+
+		for _, addressValidation := range addressValidations.all() {
+			if addressValidation.Message != "" {
+				addressValidation.Message = fmt.Sprintf("%s: %s", result.Address,
+					addressValidation.Message)
+			}
+			validations = append(validations, addressValidation)
+		}
+		verifier.logln()
+	}
+
+	chains := groupAddressesByChain(results)
+	consistencyValidation := validation.ValidationResultPass
+	if len(chains) > 1 {
+		groups := []string{}
+		for fingerprint, addresses := range chains {
+			groups = append(groups, fmt.Sprintf("%s (%.16s...)", strings.Join(addresses, ", "),
+				fingerprint))
+		}
+		sort.Strings(groups)
+
+		consistencyValidation = validation.ValidationResultFail
+		consistencyValidation.Message = fmt.Sprintf(
+			"addresses serve %d different certificate chains: %s",
+			len(chains),
+			strings.Join(groups, "; "))
+	}
+
+	consistencyValidations := verifier.add(nil, validation.ValidationTypeChainConsistency,
+		consistencyValidation, "Chain Consistency:",
+		fmt.Sprintf("%d distinct chain(s) across %d address(es)", len(chains), len(results)))
+	validations = append(validations, consistencyValidations...)
+	verifyReport.Validations = report.NewValidations(consistencyValidations)
+
+	return verifier.reportValidations(verifyReport, validations, options)
+}
+
+func (verifier *chainVerifier) verifyCertChain(
+	ctx context.Context,
+	certs []*x509.Certificate,
+	host string,
+) chainValidations {
+
+	validations := chainValidations{
+		chain:        []validation.ValidationResult{},
+		certificates: [][]validation.ValidationResult{},
+	}
 	numOfCerts := len(certs)
 
+	if numOfCerts == 0 {
+		failure := validation.ValidationResultFail
+		failure.Type = validation.ValidationTypeChain
+		failure.Message = "no certificates were retrieved"
+		validations.chain = append(validations.chain, failure)
+		return validations
+	}
+
 	// Global chain verifications
 	leafCert := certs[0]
 	hostnameValidation, _ := validation.ValidateHostname(host, leafCert)
-	validations = append(validations, hostnameValidation)
-	log.Printf("%s %-23s %s", hostnameValidation, "Hostname:", host)
+	validations.chain = verifier.add(validations.chain, validation.ValidationTypeHostname,
+		hostnameValidation, "Hostname:", host)
 
 	certChainValidation, _ := validation.ValidateChain(certs)
-	validations = append(validations, certChainValidation)
-	log.Printf("%s %-23s %s", certChainValidation, "Chain Validity:", "System CA store")
-	log.Println()
+	validations.chain = verifier.add(validations.chain, validation.ValidationTypeChain,
+		certChainValidation, "Chain Validity:", "System CA store")
+	verifier.logln()
 
 	// Inidividual cert validations
 	for idx, cert := range certs {
-		log.Printf("Certificate: %d/%d", idx+1, numOfCerts)
-		log.Println()
+		verifier.logf("Certificate: %d/%d", idx+1, numOfCerts)
+		verifier.logln()
 
 		var issuerCert *x509.Certificate
 		if idx < numOfCerts-1 {
 			issuerCert = certs[idx+1]
 		}
 
+		certValidations := []validation.ValidationResult{}
+
 		subjValidation, _ := validation.ValidateSubject(cert.Subject)
-		validations = append(validations, subjValidation)
-		log.Printf("%s %-23s '%s'", subjValidation, "Subject:", cert.Subject)
+		certValidations = verifier.add(certValidations, validation.ValidationTypeSubject,
+			subjValidation, "Subject:", fmt.Sprintf("'%s'", cert.Subject))
 
 		notBeforeValidation, _ := validation.ValidateNotBefore(cert.NotBefore)
-		validations = append(validations, notBeforeValidation)
-		log.Printf("%s %-23s %s", notBeforeValidation, "Validity (NotBefore):",
-			cert.NotBefore.Format(time.RFC3339))
+		certValidations = verifier.add(certValidations, validation.ValidationTypeNotBefore,
+			notBeforeValidation, "Validity (NotBefore):", cert.NotBefore.Format(time.RFC3339))
 
-		notAfterValidation, _ := validation.ValidateNotAfter(cert.NotAfter)
-		validations = append(validations, notAfterValidation)
-		log.Printf("%s %-23s %s", notAfterValidation, "Validity (NotAfter):",
-			cert.NotAfter.Format(time.RFC3339))
+		notAfterValidation, _ := validation.ValidateNotAfterThresholds(cert.NotAfter,
+			verifier.warnDays, verifier.critDays)
+		certValidations = verifier.add(certValidations, validation.ValidationTypeNotAfter,
+			notAfterValidation, "Validity (NotAfter):", cert.NotAfter.Format(time.RFC3339))
 
 		issuerValidation, _ := validation.ValidateIssuer(cert, issuerCert)
-		validations = append(validations, issuerValidation)
-		log.Printf("%s %-23s '%s'", issuerValidation, "Issuer:", cert.Issuer)
+		certValidations = verifier.add(certValidations, validation.ValidationTypeIssuer,
+			issuerValidation, "Issuer:", fmt.Sprintf("'%s'", cert.Issuer))
 
 		basicConstraintValidation, _ := validation.ValidateBasicConstraint(*cert)
-		validations = append(validations, basicConstraintValidation)
-		log.Printf("%s %-23s %v", basicConstraintValidation, "Basic constraint:",
-			basicConstraintValidation.Success)
+		certValidations = verifier.add(certValidations, validation.ValidationTypeBasicContstraint,
+			basicConstraintValidation, "Basic constraint:", basicConstraintValidation.Success)
 
-		crlRevocationsValidation, _ := validation.ValidateCRLRevocation(*cert, cert.CRLDistributionPoints)
-		validations = append(validations, crlRevocationsValidation)
-		log.Printf("%s %-23s %s", crlRevocationsValidation, "CRL Revocations:", cert.CRLDistributionPoints)
+		crlRevocationsValidation, _ := validation.ValidateCRLRevocation(ctx,
+			verifier.httpClient, *cert, cert.CRLDistributionPoints)
+		certValidations = verifier.add(certValidations, validation.ValidationTypeCRLRevocation,
+			crlRevocationsValidation, "CRL Revocations:", cert.CRLDistributionPoints)
 
 		// Disabled by default for now - there's a number of issues that can arise from OCSP
 		// failures on the server-side
 		/*
 			ocspRevocationsValidation, _ := validation.ValidateOCSPRevocation(
+				ctx,
+				verifier.httpClient,
 				cert,
 				issuerCert,
 				cert.OCSPServer,
 			)
-			validations = append(validations, ocspRevocationsValidation)
-			log.Printf("%s %-23s %s", ocspRevocationsValidation, "OCSP Revocations:", cert.OCSPServer)
+			certValidations = verifier.add(certValidations,
+				validation.ValidationTypeOCSPRevocation, ocspRevocationsValidation,
+				"OCSP Revocations:", cert.OCSPServer)
 		*/
 
 		caValidation, _ := validation.ValidateCA(cert.IsCA)
-		validations = append(validations, caValidation)
-		log.Printf("%s %-23s %v", caValidation, "CA Cert:", cert.IsCA)
+		certValidations = verifier.add(certValidations, validation.ValidationTypeCACert,
+			caValidation, "CA Cert:", cert.IsCA)
+
+		validations.certificates = append(validations.certificates, certValidations)
 
 		if idx < numOfCerts-1 {
-			log.Println()
+			verifier.logln()
 		}
 	}
 
-	success := true
-	for _, validation := range validations {
-		if !validation.Success {
-			if success {
-				log.Println()
-			}
-			success = false
-			log.Printf("FAIL: %s", validation.Message)
+	return validations
+}
+
+// Overall status of the validations: 'fail' if any failed, otherwise 'warn' if any warned
+func validationStatus(validations []validation.ValidationResult) string {
+	status := validation.StatusPass
+	for _, result := range validations {
+		if !result.Success {
+			return validation.StatusFail
+		}
+
+		if result.Status == validation.StatusWarn {
+			status = validation.StatusWarn
+		}
+	}
+
+	return status
+}
+
+// Logs failures and warnings and renders the report if a machine-readable format was
+// selected. The report is returned along with ErrValidationFailed if any validation failed
+// or ErrValidationWarning if any validation warned.
+func (verifier *chainVerifier) reportValidations(
+	verifyReport *report.Report,
+	validations []validation.ValidationResult,
+	options Options,
+) (string, error) {
+
+	logged := false
+	for _, result := range validations {
+		if result.Success && result.Status != validation.StatusWarn {
+			continue
+		}
+
+		if !logged {
+			verifier.logln()
+			logged = true
 		}
+
+		if result.Success {
+			verifier.logf("WARN: %s", result.Message)
+		} else {
+			verifier.logf("FAIL: %s", result.Message)
+		}
+	}
+
+	verifyReport.WarnDays = options.WarnDays
+	verifyReport.CritDays = options.CritDays
+	verifyReport.Status = validationStatus(validations)
+
+	var err error
+	switch verifyReport.Status {
+	case validation.StatusFail:
+		err = ErrValidationFailed
+	case validation.StatusWarn:
+		err = ErrValidationWarning
+	}
+
+	if options.Format == "" || options.Format == report.FormatText {
+		return "", err
 	}
 
-	if !success {
-		return "", errors.New("fetched certificate chain failed validation")
+	output, reportErr := verifyReport.Render(options.Format)
+	if reportErr != nil {
+		return "", reportErr
 	}
 
-	return "", nil
+	return output, err
 }
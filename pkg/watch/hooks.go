@@ -0,0 +1,121 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// Receives the events of a watch
+type Hook interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Posts events as JSON to a URL
+type WebhookHook struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookHook(url string, timeout time.Duration) *WebhookHook {
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	return &WebhookHook{
+		URL: url,
+		Client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (hook *WebhookHook) Notify(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL,
+		bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := hook.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.New(fmt.Sprintf("webhook '%s' responded with '%s'", hook.URL,
+			response.Status))
+	}
+
+	return nil
+}
+
+// Runs a shell command for each event. The event is passed as JSON on stdin and its main
+// fields as CRTOOL_EVENT_* environment variables.
+type ExecHook struct {
+	Command string
+	Timeout time.Duration
+}
+
+func NewExecHook(command string, timeout time.Duration) *ExecHook {
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	return &ExecHook{
+		Command: command,
+		Timeout: timeout,
+	}
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+func (hook *ExecHook) Notify(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hook.Timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, hook.Command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"CRTOOL_EVENT_TYPE="+event.Type,
+		"CRTOOL_EVENT_TARGET="+event.Target,
+		"CRTOOL_EVENT_STATUS="+event.Status,
+		"CRTOOL_EVENT_MESSAGE="+strings.ReplaceAll(event.Message, "\n", " "),
+	)
+
+	if err := cmd.Run(); err != nil {
+		return errors.New(fmt.Sprintf("command '%s' failed: %s", hook.Command, err))
+	}
+
+	return nil
+}
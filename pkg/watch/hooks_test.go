@@ -0,0 +1,85 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+)
+
+func testEvent() Event {
+	return Event{
+		Type:                 EventRotated,
+		Target:               "example.com:443",
+		Time:                 time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message:              "leaf certificate changed from AA to BB",
+		Status:               validation.StatusPass,
+		PreviousStatus:       validation.StatusPass,
+		Fingerprints:         []string{"BB"},
+		PreviousFingerprints: []string{"AA"},
+	}
+}
+
+func TestWebhookHookPostsEvent(t *testing.T) {
+	var contentType string
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter,
+		request *http.Request) {
+
+		if request.Method != http.MethodPost {
+			t.Errorf("expected a POST request but got %s", request.Method)
+		}
+
+		contentType = request.Header.Get("Content-Type")
+
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			t.Errorf("could not read the request body: %s", err)
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("could not decode the payload '%s': %s", body, err)
+		}
+
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	event := testEvent()
+	if err := NewWebhookHook(server.URL, time.Second).Notify(context.Background(),
+		event); err != nil {
+
+		t.Fatalf("expected the event to be delivered but got: %s", err)
+	}
+
+	if contentType != "application/json" {
+		t.Fatalf("expected content type 'application/json' but got '%s'", contentType)
+	}
+
+	if received.Type != event.Type || received.Target != event.Target ||
+		received.Message != event.Message || received.Status != event.Status ||
+		!received.Time.Equal(event.Time) ||
+		!sameFingerprints(received.Fingerprints, event.Fingerprints) ||
+		!sameFingerprints(received.PreviousFingerprints, event.PreviousFingerprints) {
+
+		t.Fatalf("expected payload %+v but got %+v", event, received)
+	}
+}
+
+func TestWebhookHookFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter,
+		request *http.Request) {
+
+		http.Error(writer, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := NewWebhookHook(server.URL, time.Second).Notify(context.Background(), testEvent())
+	if err == nil {
+		t.Fatalf("expected an error for a '503' response")
+	}
+}
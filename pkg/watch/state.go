@@ -0,0 +1,88 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Last observed state of a target
+type TargetState struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+
+	// Worst status of the expiry validations of the chain
+	ExpiryStatus string `json:"expiry_status,omitempty"`
+
+	// SHA-256 fingerprints of the certificates of the chain starting with the leaf
+	Fingerprints []string `json:"fingerprints,omitempty"`
+}
+
+// States of all watched targets that persist across restarts in the state file
+type State struct {
+	Targets map[string]TargetState `json:"targets"`
+}
+
+func newState() *State {
+	return &State{
+		Targets: map[string]TargetState{},
+	}
+}
+
+// Loads the state file. A missing file is an empty state.
+func LoadState(path string) (*State, error) {
+	state := newState()
+	if path == "" {
+		return state, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, errors.New(fmt.Sprintf("could not parse state file '%s': %s", path, err))
+	}
+	if state.Targets == nil {
+		state.Targets = map[string]TargetState{}
+	}
+
+	return state, nil
+}
+
+// Saves the state by replacing the file so that it is never left partially written
+func (state *State) Save(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(append(data, '\n')); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), path)
+}
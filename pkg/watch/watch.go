@@ -0,0 +1,319 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+	"github.com/sgnn7/crtool/pkg/report"
+	"github.com/sgnn7/crtool/pkg/scan"
+	"github.com/sgnn7/crtool/pkg/ssl"
+)
+
+const DefaultInterval = time.Hour
+
+// Types of watch events
+const (
+	EventRotated             = "rotated"
+	EventValidationFailed    = "validation_failed"
+	EventValidationRecovered = "validation_recovered"
+	EventExpiryThreshold     = "expiry_threshold"
+)
+
+// Severity of statuses used to detect worsening expiry statuses
+var statusSeverity = map[string]int{
+	"":                    0,
+	validation.StatusPass: 0,
+	validation.StatusSkip: 0,
+	validation.StatusWarn: 1,
+	validation.StatusFail: 2,
+}
+
+type Options struct {
+	ssl.Options
+
+	Targets []scan.Target
+
+	// Port of targets that do not specify one
+	Port string
+
+	Interval time.Duration
+
+	// File that keeps the state of the targets across restarts (optional)
+	StateFile string
+
+	// Only check the targets once instead of on every interval
+	Once bool
+
+	Hooks []Hook
+}
+
+// Change of a target that is sent to the hooks
+type Event struct {
+	Type    string    `json:"type"`
+	Target  string    `json:"target"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+
+	Fingerprints         []string `json:"fingerprints,omitempty"`
+	PreviousFingerprints []string `json:"previous_fingerprints,omitempty"`
+}
+
+func newTargetState(targetReport *report.Report, err error) TargetState {
+	state := TargetState{
+		CheckedAt: time.Now().UTC(),
+	}
+
+	if err != nil {
+		state.Status = validation.StatusFail
+		state.Error = err.Error()
+		return state
+	}
+
+	state.Status = targetReport.Status
+	state.ExpiryStatus = validation.StatusPass
+	for _, certificate := range targetReport.Certificates {
+		state.Fingerprints = append(state.Fingerprints, certificate.SHA256Fingerprint)
+
+		for _, certValidation := range certificate.Validations {
+			if certValidation.ValidationType == validation.ValidationTypeNotAfter &&
+				statusSeverity[certValidation.Status] > statusSeverity[state.ExpiryStatus] {
+
+				state.ExpiryStatus = certValidation.Status
+			}
+		}
+	}
+
+	return state
+}
+
+// Messages of the failed and warned validations of the report
+func reportMessages(targetReport *report.Report, status string) []string {
+	validations := append([]report.Validation{}, targetReport.Validations...)
+	for _, certificate := range targetReport.Certificates {
+		validations = append(validations, certificate.Validations...)
+	}
+
+	messages := []string{}
+	for _, targetValidation := range validations {
+		if targetValidation.Status == status && targetValidation.Message != "" {
+			messages = append(messages, targetValidation.Message)
+		}
+	}
+
+	return messages
+}
+
+func sameFingerprints(previous []string, current []string) bool {
+	if len(previous) != len(current) {
+		return false
+	}
+
+	for idx := range previous {
+		if previous[idx] != current[idx] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Compares the states of a target. Targets that are seen for the first time only cause an
+// event if they fail validation.
+func detectEvents(
+	target string,
+	previous *TargetState,
+	current TargetState,
+	targetReport *report.Report,
+) []Event {
+
+	newEvent := func(eventType string, message string) Event {
+		event := Event{
+			Type:         eventType,
+			Target:       target,
+			Time:         current.CheckedAt,
+			Message:      message,
+			Status:       current.Status,
+			Fingerprints: current.Fingerprints,
+		}
+
+		if previous != nil {
+			event.PreviousStatus = previous.Status
+			event.PreviousFingerprints = previous.Fingerprints
+		}
+
+		return event
+	}
+
+	events := []Event{}
+
+	previousStatus := ""
+	if previous != nil {
+		previousStatus = previous.Status
+	}
+
+	if current.Status == validation.StatusFail && previousStatus != validation.StatusFail {
+		message := current.Error
+		if targetReport != nil {
+			message = strings.Join(reportMessages(targetReport, validation.StatusFail), "; ")
+		}
+		events = append(events, newEvent(EventValidationFailed, message))
+	}
+
+	if previous == nil {
+		return events
+	}
+
+	if previousStatus == validation.StatusFail && current.Status != validation.StatusFail {
+		events = append(events, newEvent(EventValidationRecovered,
+			fmt.Sprintf("chain passed validation with status '%s'", current.Status)))
+	}
+
+	// Chains that could not be retrieved are compared once they can be again
+	if current.Error != "" || len(previous.Fingerprints) == 0 {
+		return events
+	}
+
+	if !sameFingerprints(previous.Fingerprints, current.Fingerprints) {
+		message := "certificate chain changed"
+		if len(previous.Fingerprints) > 0 && len(current.Fingerprints) > 0 &&
+			previous.Fingerprints[0] != current.Fingerprints[0] {
+
+			message = fmt.Sprintf("leaf certificate changed from %s to %s",
+				previous.Fingerprints[0], current.Fingerprints[0])
+		}
+		events = append(events, newEvent(EventRotated, message))
+	}
+
+	if statusSeverity[current.ExpiryStatus] > statusSeverity[previous.ExpiryStatus] {
+		messages := reportMessages(targetReport, current.ExpiryStatus)
+		message := fmt.Sprintf("expiry status changed from '%s' to '%s'",
+			previous.ExpiryStatus, current.ExpiryStatus)
+		if len(messages) > 0 {
+			message = fmt.Sprintf("%s: %s", message, strings.Join(messages, "; "))
+		}
+		events = append(events, newEvent(EventExpiryThreshold, message))
+	}
+
+	return events
+}
+
+// Detects the events of the target's new state and stores it. Failed checks keep the chain
+// and expiry status of the last successful one so that rotations during outages are still
+// detected and expiry thresholds are not crossed again after them.
+func (state *State) update(
+	name string,
+	current TargetState,
+	targetReport *report.Report,
+) []Event {
+
+	var previous *TargetState
+	if previousState, ok := state.Targets[name]; ok {
+		previous = &previousState
+	}
+
+	events := detectEvents(name, previous, current, targetReport)
+
+	if current.Error != "" && previous != nil {
+		current.Fingerprints = previous.Fingerprints
+		current.ExpiryStatus = previous.ExpiryStatus
+	}
+	state.Targets[name] = current
+
+	return events
+}
+
+func notify(ctx context.Context, event Event, options Options) {
+	log.Printf("Event '%s' of '%s': %s", event.Type, event.Target, event.Message)
+
+	for _, hook := range options.Hooks {
+		if err := hook.Notify(ctx, event); err != nil {
+			log.Printf("Could not notify about event '%s' of '%s': %s", event.Type, event.Target,
+				err)
+		}
+	}
+}
+
+// Checks every target once, notifies the hooks of events and updates the state
+func checkTargets(ctx context.Context, state *State, options Options) {
+	for _, target := range options.Targets {
+		if ctx.Err() != nil {
+			return
+		}
+
+		port := target.Port
+		if port == "" {
+			port = options.Port
+		}
+
+		name := target.String()
+		targetReport, err := ssl.ValidateServerCertChain(ctx, target.Target, port,
+			options.Options)
+
+		// Interrupted checks say nothing about the target
+		if ctx.Err() != nil {
+			return
+		}
+
+		current := newTargetState(targetReport, err)
+		if options.Debug {
+			log.Printf("Checked '%s': status '%s'", name, current.Status)
+		}
+
+		for _, event := range state.update(name, current, targetReport) {
+			notify(ctx, event, options)
+		}
+	}
+}
+
+// Checks the targets on every interval until the context is done
+func Run(ctx context.Context, options Options) error {
+	if len(options.Targets) == 0 {
+		return errors.New("no targets to watch")
+	}
+
+	interval := options.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	state, err := LoadState(options.StateFile)
+	if err != nil {
+		return err
+	}
+
+	options.ValidationClient = ssl.NewValidationHTTPClient(options.Options)
+
+	if !options.Once {
+		log.Printf("Watching %d target(s) every %s", len(options.Targets), interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkTargets(ctx, state, options)
+
+		if err := state.Save(options.StateFile); err != nil {
+			return errors.New(fmt.Sprintf("could not save state file '%s': %s",
+				options.StateFile, err))
+		}
+
+		if options.Once {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package watch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sgnn7/crtool/pkg/certificates/validation"
+	"github.com/sgnn7/crtool/pkg/report"
+)
+
+func checkedState(status string, expiryStatus string, fingerprints ...string) TargetState {
+	state := newTargetState(&report.Report{Status: status}, nil)
+	state.ExpiryStatus = expiryStatus
+	state.Fingerprints = fingerprints
+
+	return state
+}
+
+func eventTypes(events []Event) []string {
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+func assertEvents(t *testing.T, step string, events []Event, expected ...string) {
+	t.Helper()
+
+	types := eventTypes(events)
+	if len(types) != len(expected) {
+		t.Fatalf("%s: expected events %v but got %v", step, expected, types)
+	}
+	for idx := range expected {
+		if types[idx] != expected[idx] {
+			t.Fatalf("%s: expected events %v but got %v", step, expected, types)
+		}
+	}
+}
+
+func TestRotationDuringOutage(t *testing.T) {
+	state := newState()
+	target := "example.com:443"
+
+	events := state.update(target,
+		checkedState(validation.StatusPass, validation.StatusPass, "AA"), &report.Report{})
+	assertEvents(t, "first check", events)
+
+	events = state.update(target,
+		newTargetState(nil, errors.New("connection refused")), nil)
+	assertEvents(t, "outage", events, EventValidationFailed)
+
+	if fingerprints := state.Targets[target].Fingerprints; len(fingerprints) != 1 ||
+		fingerprints[0] != "AA" {
+
+		t.Fatalf("outage: expected the last chain to be kept but got %v", fingerprints)
+	}
+
+	events = state.update(target,
+		checkedState(validation.StatusPass, validation.StatusPass, "BB"), &report.Report{})
+	assertEvents(t, "recovery", events, EventValidationRecovered, EventRotated)
+
+	rotated := events[1]
+	if rotated.PreviousFingerprints[0] != "AA" || rotated.Fingerprints[0] != "BB" {
+		t.Fatalf("recovery: expected rotation from AA to BB but got %v to %v",
+			rotated.PreviousFingerprints, rotated.Fingerprints)
+	}
+}
+
+func TestExpiryThresholdNotRepeatedAfterOutage(t *testing.T) {
+	state := newState()
+	target := "example.com:443"
+
+	state.update(target,
+		checkedState(validation.StatusPass, validation.StatusPass, "AA"), &report.Report{})
+
+	events := state.update(target,
+		checkedState(validation.StatusWarn, validation.StatusWarn, "AA"), &report.Report{})
+	assertEvents(t, "threshold", events, EventExpiryThreshold)
+
+	events = state.update(target,
+		newTargetState(nil, errors.New("connection refused")), nil)
+	assertEvents(t, "outage", events, EventValidationFailed)
+
+	events = state.update(target,
+		checkedState(validation.StatusWarn, validation.StatusWarn, "AA"), &report.Report{})
+	assertEvents(t, "recovery", events, EventValidationRecovered)
+}